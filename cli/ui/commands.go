@@ -1,6 +1,9 @@
 package ui
 
 import (
+	"time"
+
+	"github.com/bobby/slurpy/pkg/intercept"
 	"github.com/bobby/slurpy/pkg/models"
 	"github.com/bobby/slurpy/pkg/storage"
 	tea "github.com/charmbracelet/bubbletea"
@@ -23,6 +26,27 @@ type errMsg struct {
 	err error
 }
 
+// replayTickMsg advances a message stream replay. gen identifies the replay
+// that scheduled it, so ticks from a stopped replay are ignored.
+type replayTickMsg struct {
+	gen int
+	at  time.Time
+}
+
+// interceptedMsg delivers a request or response paused at a breakpoint
+type interceptedMsg struct {
+	paused *intercept.Paused
+}
+
+// interceptGoneMsg reports that a paused exchange stopped waiting, e.g.
+// because the program canceled the request
+type interceptGoneMsg struct {
+	paused *intercept.Paused
+}
+
+// replayFrame is how often a replay redraws
+const replayFrame = 50 * time.Millisecond
+
 // loadRequestsCmd loads requests for a namespace
 func loadRequestsCmd(storage *storage.Storage, namespace string) tea.Cmd {
 	return func() tea.Msg {
@@ -62,3 +86,39 @@ func clearNamespaceCmd(storage *storage.Storage, namespace string) tea.Cmd {
 		return namespacesClearedMsg{err}
 	}
 }
+
+// replayTickCmd schedules the next frame of a replay
+func replayTickCmd(gen int) tea.Cmd {
+	return tea.Tick(replayFrame, func(t time.Time) tea.Msg {
+		return replayTickMsg{gen: gen, at: t}
+	})
+}
+
+// acceptInterceptCmd waits for the next exchange paused at a breakpoint. It
+// stops once the listener is closed.
+func acceptInterceptCmd(l *intercept.Listener) tea.Cmd {
+	return func() tea.Msg {
+		paused, err := l.Accept()
+		if err != nil {
+			return nil
+		}
+		return interceptedMsg{paused}
+	}
+}
+
+// watchInterceptCmd reports when a paused exchange stops waiting
+func watchInterceptCmd(paused *intercept.Paused) tea.Cmd {
+	return func() tea.Msg {
+		<-paused.Gone()
+		return interceptGoneMsg{paused}
+	}
+}
+
+// resolveInterceptCmd sends the verdict for a paused exchange. Exchanges the
+// program gave up on meanwhile are ignored.
+func resolveInterceptCmd(paused *intercept.Paused, verdict models.Verdict) tea.Cmd {
+	return func() tea.Msg {
+		paused.Resolve(verdict)
+		return nil
+	}
+}
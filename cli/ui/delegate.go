@@ -61,7 +61,13 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 	status := "●"
 	statusColor := lipgloss.Color("#888888")
 
-	if i.Response != nil {
+	if i.GRPC != nil && i.GRPC.Code != "" {
+		if i.GRPC.Code == "OK" {
+			statusColor = successColor
+		} else {
+			statusColor = errorColor
+		}
+	} else if i.Response != nil {
 		if i.Response.StatusCode >= 200 && i.Response.StatusCode < 300 {
 			statusColor = successColor
 		} else if i.Response.StatusCode >= 400 {
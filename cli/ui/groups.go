@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"sort"
+
+	"github.com/bobby/slurpy/pkg/models"
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// buildItems turns requests into list items, folding each redirect chain
+// under its original request. Chains listed in expanded show every hop.
+func buildItems(requests []*models.LoggedRequest, expanded map[string]bool) []list.Item {
+	byID := make(map[string]*models.LoggedRequest, len(requests))
+	for _, req := range requests {
+		byID[req.ID] = req
+	}
+
+	hops := make(map[string][]*models.LoggedRequest)
+	var roots []*models.LoggedRequest
+	for _, req := range requests {
+		root := chainRoot(req, byID)
+		if root == req {
+			roots = append(roots, req)
+		} else {
+			hops[root.ID] = append(hops[root.ID], req)
+		}
+	}
+
+	items := make([]list.Item, 0, len(requests))
+	for _, root := range roots {
+		chain := hops[root.ID]
+		sort.Slice(chain, func(i, j int) bool {
+			return chain[i].RedirectIndex < chain[j].RedirectIndex
+		})
+
+		open := expanded[root.ID]
+		items = append(items, requestItem{LoggedRequest: root, hops: chain, expanded: open})
+		if open {
+			for _, hop := range chain {
+				items = append(items, requestItem{LoggedRequest: hop, root: root})
+			}
+		}
+	}
+
+	return items
+}
+
+// chainRoot follows ParentID links back to the request that started a chain
+func chainRoot(req *models.LoggedRequest, byID map[string]*models.LoggedRequest) *models.LoggedRequest {
+	// Bound the walk so a corrupt log with a cycle can't hang the UI
+	for i := 0; i < len(byID) && req.ParentID != ""; i++ {
+		parent, ok := byID[req.ParentID]
+		if !ok {
+			break
+		}
+		req = parent
+	}
+	return req
+}
@@ -0,0 +1,163 @@
+package ui
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bobby/slurpy/pkg/intercept"
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// pausedExchange is a request or response waiting at a breakpoint, with the
+// changes made to it so far
+type pausedExchange struct {
+	*intercept.Paused
+	edited *models.Interception // Sent on continue; nil sends it unchanged
+}
+
+// current returns the message as it will be sent
+func (p *pausedExchange) current() *models.Interception {
+	if p.edited != nil {
+		return p.edited
+	}
+	return &p.Interception
+}
+
+// bodyEditable reports whether the body is part of the editable text. Binary
+// bodies and ones the SDK left out are sent unchanged.
+func bodyEditable(ic *models.Interception) bool {
+	return !ic.BodyOmitted && ic.BodyEncoding == ""
+}
+
+// formatInterception renders a paused message as editable text: a start line
+// ("POST https://..." or "503 Service Unavailable"), one header per line, a
+// blank line and the body
+func formatInterception(ic *models.Interception) string {
+	var b strings.Builder
+
+	if ic.Stage == models.StageResponse {
+		b.WriteString(fmt.Sprintf("%d %s\n", ic.StatusCode, http.StatusText(ic.StatusCode)))
+	} else {
+		b.WriteString(fmt.Sprintf("%s %s\n", ic.Method, ic.URL))
+	}
+
+	names := make([]string, 0, len(ic.Headers))
+	for k := range ic.Headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range ic.Headers[k] {
+			b.WriteString(fmt.Sprintf("%s: %s\n", k, v))
+		}
+	}
+
+	if bodyEditable(ic) {
+		b.WriteString("\n")
+		b.WriteString(ic.Body)
+	}
+	return b.String()
+}
+
+// parseInterception reads text written in the format of formatInterception
+// back into a copy of ic
+func parseInterception(ic *models.Interception, text string) (*models.Interception, error) {
+	edited := *ic
+	lines := strings.Split(text, "\n")
+
+	start := strings.Fields(lines[0])
+	if ic.Stage == models.StageResponse {
+		if len(start) == 0 {
+			return nil, fmt.Errorf("missing status code on the first line")
+		}
+		code, err := strconv.Atoi(start[0])
+		if err != nil || code < 100 || code > 999 {
+			return nil, fmt.Errorf("invalid status %q: expected a code such as 200", start[0])
+		}
+		edited.StatusCode = code
+	} else {
+		if len(start) != 2 {
+			return nil, fmt.Errorf("invalid first line %q: expected METHOD URL", lines[0])
+		}
+		if u, err := url.Parse(start[1]); err != nil || !u.IsAbs() {
+			return nil, fmt.Errorf("invalid URL %q: expected an absolute URL", start[1])
+		}
+		edited.Method, edited.URL = strings.ToUpper(start[0]), start[1]
+	}
+
+	edited.Headers = models.Headers{}
+	i := 1
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		name, value, ok := strings.Cut(lines[i], ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header line %q: expected Name: value", lines[i])
+		}
+		key := http.CanonicalHeaderKey(name)
+		edited.Headers[key] = append(edited.Headers[key], strings.TrimSpace(value))
+	}
+
+	// Everything after the blank line is the body
+	if i < len(lines) {
+		body := strings.Join(lines[i+1:], "\n")
+		switch {
+		case bodyEditable(ic):
+			edited.Body = body
+		case body != "":
+			return nil, fmt.Errorf("the body of this %s cannot be edited", ic.Stage)
+		}
+	} else if bodyEditable(ic) {
+		edited.Body = ""
+	}
+	return &edited, nil
+}
+
+// renderInterception renders the first of n paused exchanges, with the
+// editor in place of the message while it is being edited
+func renderInterception(p *pausedExchange, n int, editor string, problem string) string {
+	var b strings.Builder
+
+	ic := p.current()
+	header := fmt.Sprintf("PAUSED AT BREAKPOINT %s", ic.Breakpoint)
+	if n > 1 {
+		header += fmt.Sprintf(" (1 of %d)", n)
+	}
+	b.WriteString(headerStyle.Render(header))
+	b.WriteString("\n\n")
+	if ic.Stage == models.StageResponse {
+		b.WriteString(fmt.Sprintf("Response to %s %s\n", p.Method, p.URL))
+	} else {
+		b.WriteString("Request, not sent yet\n")
+	}
+	b.WriteString(fmt.Sprintf("Namespace: %s\n", ic.Namespace))
+	switch {
+	case ic.BodyOmitted:
+		b.WriteString("Body: too large or streamed, sent unchanged\n")
+	case !bodyEditable(ic):
+		b.WriteString("Body: binary, sent unchanged\n")
+	}
+	if p.edited != nil {
+		b.WriteString(selectedMessageStyle.Render("Edited") + "\n")
+	}
+	b.WriteString("\n")
+
+	if editor != "" {
+		b.WriteString(editor)
+		b.WriteString("\n\n")
+		if problem != "" {
+			b.WriteString(truncatedStyle.Render(problem))
+			b.WriteString("\n")
+		}
+		b.WriteString("ctrl+s save • esc discard changes")
+		return b.String()
+	}
+
+	b.WriteString(renderBody(formatInterception(ic), "", "", 0, false, 1000))
+	b.WriteString("\n\n")
+	b.WriteString("g continue • e edit • x abort")
+	return b.String()
+}
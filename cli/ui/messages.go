@@ -0,0 +1,142 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// messageRows is how many messages the messages view lists at once
+const messageRows = 12
+
+// stallGap is the pause between messages that is highlighted as a stall
+const stallGap = 500 * time.Millisecond
+
+// renderMessageView renders the stream messages of a request one per line,
+// like a browser's Messages tab, with the payload of the selected message
+// shown in full below. While a replay is running, elapsed is how far into the
+// stream it is and only messages that had arrived by then are shown, with the
+// latest one selected; elapsed is negative otherwise.
+func renderMessageView(req *models.LoggedRequest, cursor int, elapsed time.Duration) string {
+	var b strings.Builder
+
+	messages := req.Messages
+	b.WriteString(headerStyle.Render(fmt.Sprintf("MESSAGES (%d)", len(messages))))
+	b.WriteString("\n")
+	b.WriteString(req.URL)
+	b.WriteString("\n")
+	if req.MessagesDropped > 0 {
+		b.WriteString(truncatedStyle.Render(fmt.Sprintf("[%d later messages not stored]", req.MessagesDropped)))
+		b.WriteString("\n")
+	}
+	if gap, i := longestGap(messages); gap > 0 {
+		b.WriteString(fmt.Sprintf("Longest gap: %v before message %d\n", gap.Truncate(time.Millisecond), i+1))
+	}
+
+	if elapsed >= 0 && len(messages) > 0 {
+		total := messages[len(messages)-1].Offset
+		b.WriteString(selectedMessageStyle.Render(fmt.Sprintf("REPLAY %v / %v", elapsed.Truncate(10*time.Millisecond), total.Truncate(10*time.Millisecond))))
+		b.WriteString("  (p to stop)\n")
+
+		shown := 0
+		for shown < len(messages) && messages[shown].Offset <= elapsed {
+			shown++
+		}
+		messages, cursor = messages[:shown], shown-1
+	}
+	b.WriteString("\n")
+
+	if len(req.Messages) == 0 {
+		b.WriteString("No messages")
+		return b.String()
+	}
+	if len(messages) == 0 {
+		b.WriteString("Waiting for the first message...")
+		return b.String()
+	}
+
+	// Keep the cursor in view
+	first := cursor - messageRows/2
+	if first > len(messages)-messageRows {
+		first = len(messages) - messageRows
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	for i := first; i < len(messages) && i < first+messageRows; i++ {
+		var prev time.Duration
+		if i > 0 {
+			prev = messages[i-1].Offset
+		}
+		line := messageLine(messages[i], prev)
+		if i == cursor {
+			b.WriteString(selectedMessageStyle.Render("▶ ") + line)
+		} else {
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n")
+	}
+
+	msg := messages[cursor]
+	b.WriteString("\n")
+	title := fmt.Sprintf("Message %d of %d", cursor+1, len(req.Messages))
+	if msg.ID != "" {
+		title += fmt.Sprintf(" (id %s)", msg.ID)
+	}
+	b.WriteString(subHeaderStyle.Render(title + ":"))
+	b.WriteString("\n")
+	if msg.Data == "" && msg.Size > 0 && !msg.Truncated {
+		// Chunks of a streamed body are stored in the body, not again here
+		b.WriteString(fmt.Sprintf("[%d bytes, part of the response body]", msg.Size))
+	} else {
+		b.WriteString(renderBody(msg.Data, msg.Encoding, "", msg.Size, msg.Truncated, 600))
+	}
+
+	return strings.ReplaceAll(b.String(), models.RedactedValue, redactedStyle.Render(models.RedactedValue))
+}
+
+// messageLine summarizes a message on a single line: its offset from the
+// start of the request, the gap since the previous message, the direction,
+// type, size and a preview of the payload. Gaps long enough to be a stall
+// are highlighted.
+func messageLine(msg models.Message, prev time.Duration) string {
+	arrow := "←"
+	if msg.IsSent() {
+		arrow = "→"
+	}
+
+	preview := msg.Data
+	if msg.Encoding != "" {
+		preview = "[binary]"
+	}
+	preview = strings.Join(strings.Fields(preview), " ")
+	if utf8.RuneCountInString(preview) > 16 {
+		preview = string([]rune(preview)[:16]) + "…"
+	}
+
+	gap := msg.Offset - prev
+	gapText := fmt.Sprintf("%8s", "+"+gap.Truncate(time.Millisecond).String())
+	if gap >= stallGap {
+		gapText = stallStyle.Render(gapText)
+	}
+
+	return fmt.Sprintf("%8v %s %s %-7s %6dB %s", msg.Offset.Truncate(time.Millisecond), gapText, arrow, msg.Type, msg.Size, preview)
+}
+
+// longestGap returns the longest pause between consecutive messages and the
+// index of the message that ended it. The wait for the first message is not
+// counted, since that is the server's response time.
+func longestGap(messages []models.Message) (time.Duration, int) {
+	var longest time.Duration
+	index := 0
+	for i := 1; i < len(messages); i++ {
+		if gap := messages[i].Offset - messages[i-1].Offset; gap > longest {
+			longest, index = gap, i
+		}
+	}
+	return longest, index
+}
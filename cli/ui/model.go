@@ -1,28 +1,46 @@
 package ui
 
 import (
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/bobby/slurpy/pkg/intercept"
 	"github.com/bobby/slurpy/pkg/models"
+	"github.com/bobby/slurpy/pkg/settings"
 	"github.com/bobby/slurpy/pkg/storage"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
+// certExpiryWarning is how close to expiry a certificate gets flagged
+const certExpiryWarning = 30 * 24 * time.Hour
+
 // keyMap defines the key bindings
 type keyMap struct {
-	Up      key.Binding
-	Down    key.Binding
-	Left    key.Binding
-	Right   key.Binding
-	Help    key.Binding
-	Quit    key.Binding
-	Refresh key.Binding
-	Clear   key.Binding
-	Tab     key.Binding
+	Up        key.Binding
+	Down      key.Binding
+	Left      key.Binding
+	Right     key.Binding
+	Help      key.Binding
+	Quit      key.Binding
+	Refresh   key.Binding
+	Clear     key.Binding
+	Tab       key.Binding
+	Expand    key.Binding
+	Direction key.Binding
+	Messages  key.Binding
+	Replay    key.Binding
+	Continue  key.Binding
+	Edit      key.Binding
+	Abort     key.Binding
+	Save      key.Binding
 }
 
 // ShortHelp returns key help
@@ -34,7 +52,8 @@ func (k keyMap) ShortHelp() []key.Binding {
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Up, k.Down, k.Left, k.Right},
-		{k.Tab, k.Refresh, k.Clear},
+		{k.Tab, k.Expand, k.Direction, k.Messages, k.Replay, k.Refresh, k.Clear},
+		{k.Continue, k.Edit, k.Abort, k.Save},
 		{k.Help, k.Quit},
 	}
 }
@@ -76,8 +95,47 @@ var keys = keyMap{
 		key.WithKeys("tab"),
 		key.WithHelp("tab", "switch focus"),
 	),
+	Expand: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "expand/collapse redirects"),
+	),
+	Direction: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "filter inbound/outbound"),
+	),
+	Messages: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "toggle messages view"),
+	),
+	Replay: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "replay message stream"),
+	),
+	Continue: key.NewBinding(
+		key.WithKeys("g"),
+		key.WithHelp("g", "continue paused request"),
+	),
+	Edit: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "edit paused request"),
+	),
+	Abort: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "abort paused request"),
+	),
+	Save: key.NewBinding(
+		key.WithKeys("ctrl+s"),
+		key.WithHelp("ctrl+s", "save edits"),
+	),
 }
 
+// editorWidth and editorHeight size the paused message editor to fit the
+// details panel
+const (
+	editorWidth  = 56
+	editorHeight = 18
+)
+
 // Model represents the application state
 type Model struct {
 	requests     []*models.LoggedRequest
@@ -89,27 +147,75 @@ type Model struct {
 	height       int
 	focusedPanel int // 0 = list, 1 = details
 	showHelp     bool
+	expanded     map[string]bool // Redirect chains shown hop by hop, by root ID
+	direction    string          // Only show this direction; "" shows both
+	showMessages bool            // Details panel shows the message stream
+	msgCursor    int             // Selected message in the messages view
+	msgFor       string          // Request the message cursor belongs to
+	replayFor    string          // Request whose stream is replaying; "" when none is
+	replayStart  time.Time       // When the replay started
+	replayNow    time.Time       // Time of the latest replay frame
+	replayGen    int             // Incremented per replay to drop stale ticks
+	intercepts   *intercept.Listener
+	interceptErr error             // Why breakpoints cannot pause here; nil when they can
+	paused       []*pausedExchange // Waiting at breakpoints, oldest first
+	editing      bool              // The oldest paused exchange is open in the editor
+	editor       textarea.Model
+	editProblem  string // Why the last save was rejected
 	err          error
 }
 
 // requestItem wraps LoggedRequest for the list component
 type requestItem struct {
 	*models.LoggedRequest
+	hops     []*models.LoggedRequest // Redirect hops following this request
+	expanded bool
+	root     *models.LoggedRequest // Set on hops shown under their chain
+}
+
+// chainID returns the ID of the request that started this item's chain
+func (i requestItem) chainID() string {
+	if i.root != nil {
+		return i.root.ID
+	}
+	return i.ID
 }
 
 func (i requestItem) FilterValue() string {
-	return fmt.Sprintf("%s %s %s", i.Method, i.URL, i.Namespace)
+	value := fmt.Sprintf("%s %s %s %s", i.Method, i.URL, i.Namespace, strings.Join(i.Tags, " "))
+	if i.Fault != nil {
+		value += " fault"
+	}
+	if i.Breakpoint != nil {
+		value += " breakpoint"
+	}
+	return value
 }
 
 func (i requestItem) Title() string {
 	status := "PENDING"
-	if i.Response != nil {
+	switch {
+	case i.GRPC != nil && i.GRPC.Code != "":
+		status = i.GRPC.Code
+	case i.Response != nil:
 		status = fmt.Sprintf("%d", i.Response.StatusCode)
-	} else if i.Error != "" {
+	case i.Error != "":
 		status = "ERROR"
 	}
 
-	return fmt.Sprintf("%s %s [%s]", i.Method, i.URL, status)
+	title := fmt.Sprintf("%s %s [%s]", i.Method, i.URL, status)
+	if i.Fault != nil {
+		title = "⚡ " + title
+	}
+	switch {
+	case i.root != nil:
+		title = "↳ " + title
+	case len(i.hops) > 0 && i.expanded:
+		title = fmt.Sprintf("▾ %s (+%d redirects)", title, len(i.hops))
+	case len(i.hops) > 0:
+		title = fmt.Sprintf("▸ %s (+%d redirects)", title, len(i.hops))
+	}
+	return title
 }
 
 func (i requestItem) Description() string {
@@ -117,6 +223,21 @@ func (i requestItem) Description() string {
 	timeStr := i.Timestamp.Format("15:04:05")
 
 	desc := fmt.Sprintf("%s • %s • %s", timeStr, duration, i.Namespace)
+	if i.IsInbound() {
+		desc += " • inbound"
+	}
+	if len(i.Tags) > 0 {
+		desc += " • #" + strings.Join(i.Tags, " #")
+	}
+	if i.Network != nil {
+		desc += " • " + i.Network.Name
+	}
+	if i.Breakpoint != nil {
+		desc += " • paused at " + i.Breakpoint.Name
+	}
+	if i.Fault != nil {
+		desc += " • fault: " + i.Fault.String()
+	}
 	if i.Error != "" {
 		desc += " • " + i.Error
 	}
@@ -125,7 +246,14 @@ func (i requestItem) Description() string {
 
 // InitialModel creates the initial application model
 func InitialModel() Model {
-	storage, err := storage.New()
+	// Read the same config file and environment as the SDK, so the CLI
+	// finds logs written to a custom store dir
+	config, err := settings.Load("")
+	if err != nil {
+		return Model{err: err}
+	}
+
+	storage, err := storage.NewWithDir(config.StoreDir)
 	if err != nil {
 		return Model{err: err}
 	}
@@ -133,7 +261,7 @@ func InitialModel() Model {
 	// Create list
 	items := []list.Item{}
 	l := list.New(items, itemDelegate{}, 0, 0)
-	l.Title = "HTTP Requests"
+	l.Title = listTitle("")
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(true)
 	l.Styles.Title = titleStyle
@@ -145,17 +273,30 @@ func InitialModel() Model {
 		list:         l,
 		focusedPanel: 0,
 		currentNS:    "all",
+		expanded:     make(map[string]bool),
 	}
 
+	// Serve the SDK's breakpoints. Without the socket the CLI still browses
+	// logs, and paused requests continue as if no CLI was running.
+	socket, err := intercept.SocketPath(config.StoreDir)
+	if err == nil {
+		model.intercepts, err = intercept.Listen(socket)
+	}
+	model.interceptErr = err
+
 	return model
 }
 
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(
+	cmds := []tea.Cmd{
 		loadRequestsCmd(m.storage, m.currentNS),
 		loadNamespacesCmd(m.storage),
-	)
+	}
+	if m.intercepts != nil {
+		cmds = append(cmds, acceptInterceptCmd(m.intercepts))
+	}
+	return tea.Batch(cmds...)
 }
 
 // Update handles messages
@@ -175,10 +316,53 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.list.SetHeight(listHeight)
 
 	case tea.KeyMsg:
+		// The editor takes every key until it is saved or discarded
+		if m.editing {
+			switch {
+			case key.Matches(msg, keys.Save):
+				edited, err := parseInterception(&m.paused[0].Interception, m.editor.Value())
+				if err != nil {
+					m.editProblem = err.Error()
+					return m, nil
+				}
+				m.paused[0].edited, m.editing = edited, false
+			case msg.Type == tea.KeyEsc:
+				m.editing = false
+			default:
+				m.editor, cmd = m.editor.Update(msg)
+				return m, cmd
+			}
+			return m, nil
+		}
+
 		switch {
 		case key.Matches(msg, keys.Quit):
+			// Requests still paused continue unchanged
+			if m.intercepts != nil {
+				m.intercepts.Close()
+			}
 			return m, tea.Quit
 
+		case key.Matches(msg, keys.Continue) && len(m.paused) > 0 && m.list.FilterState() != list.Filtering:
+			p := m.paused[0]
+			m.paused = m.paused[1:]
+			return m, resolveInterceptCmd(p.Paused, models.Verdict{Edited: p.edited})
+
+		case key.Matches(msg, keys.Abort) && len(m.paused) > 0 && m.list.FilterState() != list.Filtering:
+			p := m.paused[0]
+			m.paused = m.paused[1:]
+			return m, resolveInterceptCmd(p.Paused, models.Verdict{Abort: true})
+
+		case key.Matches(msg, keys.Edit) && len(m.paused) > 0 && m.list.FilterState() != list.Filtering:
+			m.editor = textarea.New()
+			m.editor.ShowLineNumbers = false
+			m.editor.CharLimit, m.editor.MaxHeight = 0, 0
+			m.editor.SetWidth(editorWidth)
+			m.editor.SetHeight(editorHeight)
+			m.editor.SetValue(formatInterception(m.paused[0].current()))
+			m.editing, m.editProblem = true, ""
+			return m, m.editor.Focus()
+
 		case key.Matches(msg, keys.Help):
 			m.showHelp = !m.showHelp
 
@@ -195,6 +379,51 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.focusedPanel = 0
 			}
 
+		case key.Matches(msg, keys.Expand) && m.list.FilterState() != list.Filtering:
+			if item, ok := m.list.SelectedItem().(requestItem); ok && (len(item.hops) > 0 || item.root != nil) {
+				rootID := item.chainID()
+				m.expanded[rootID] = !m.expanded[rootID]
+				m.list.SetItems(buildItems(m.visibleRequests(), m.expanded))
+				m.selectRequest(rootID)
+				return m, nil
+			}
+
+		case key.Matches(msg, keys.Direction) && m.list.FilterState() != list.Filtering:
+			switch m.direction {
+			case "":
+				m.direction = models.DirectionOutbound
+			case models.DirectionOutbound:
+				m.direction = models.DirectionInbound
+			default:
+				m.direction = ""
+			}
+			m.list.Title = listTitle(m.direction)
+			m.list.SetItems(buildItems(m.visibleRequests(), m.expanded))
+			return m, nil
+
+		case key.Matches(msg, keys.Messages) && m.list.FilterState() != list.Filtering:
+			m.showMessages = !m.showMessages
+			m.replayFor = ""
+			return m, nil
+
+		case key.Matches(msg, keys.Replay) && m.list.FilterState() != list.Filtering && m.showMessages:
+			// p stops a running replay and starts a finished one over
+			item, ok := m.list.SelectedItem().(requestItem)
+			if !ok || len(item.Messages) == 0 || (m.replayFor == item.ID && !m.replayFinished(item.LoggedRequest)) {
+				m.replayFor = ""
+				return m, nil
+			}
+			now := time.Now()
+			m.replayFor, m.replayStart, m.replayNow = item.ID, now, now
+			m.replayGen++
+			return m, replayTickCmd(m.replayGen)
+
+		case (key.Matches(msg, keys.Up) || key.Matches(msg, keys.Down)) && m.focusedPanel == 1 && m.showMessages:
+			if item, ok := m.list.SelectedItem().(requestItem); ok {
+				m.moveMessageCursor(item.LoggedRequest, key.Matches(msg, keys.Down))
+			}
+			return m, nil
+
 		case key.Matches(msg, keys.Clear):
 			if m.currentNS != "all" && m.currentNS != "" {
 				return m, clearNamespaceCmd(m.storage, m.currentNS)
@@ -203,11 +432,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case requestsLoadedMsg:
 		m.requests = msg.requests
-		items := make([]list.Item, len(m.requests))
-		for i, req := range m.requests {
-			items[i] = requestItem{req}
-		}
-		m.list.SetItems(items)
+		m.list.SetItems(buildItems(m.visibleRequests(), m.expanded))
 
 	case namespacesLoadedMsg:
 		m.namespaces = msg.namespaces
@@ -219,6 +444,35 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, loadRequestsCmd(m.storage, m.currentNS)
 		}
 
+	case interceptedMsg:
+		m.paused = append(m.paused, &pausedExchange{Paused: msg.paused})
+		return m, tea.Batch(acceptInterceptCmd(m.intercepts), watchInterceptCmd(msg.paused))
+
+	case interceptGoneMsg:
+		// Resolved exchanges have left the queue already
+		for i, p := range m.paused {
+			if p.Paused == msg.paused {
+				m.paused = append(m.paused[:i:i], m.paused[i+1:]...)
+				if i == 0 {
+					m.editing = false
+				}
+				break
+			}
+		}
+		return m, nil
+
+	case replayTickMsg:
+		if msg.gen != m.replayGen || m.replayFor == "" {
+			return m, nil
+		}
+		m.replayNow = msg.at
+		// Keep the last frame on screen once every message has arrived
+		if item, ok := m.list.SelectedItem().(requestItem); ok && item.ID == m.replayFor && m.replayFinished(item.LoggedRequest) {
+			m.msgFor, m.msgCursor = item.ID, len(item.Messages)-1
+			return m, nil
+		}
+		return m, replayTickCmd(m.replayGen)
+
 	case errMsg:
 		m.err = msg.err
 	}
@@ -253,8 +507,28 @@ func (m Model) View() string {
 	}
 
 	var rightPanel string
-	if len(m.requests) > 0 && m.list.Index() < len(m.requests) {
-		rightPanel = detailsStyle.Render(m.renderRequestDetails(m.requests[m.list.Index()]))
+	if len(m.paused) > 0 {
+		editor := ""
+		if m.editing {
+			editor = m.editor.View()
+		}
+		rightPanel = detailsStyle.Render(renderInterception(m.paused[0], len(m.paused), editor, m.editProblem))
+	} else if item, ok := m.list.SelectedItem().(requestItem); ok && m.showMessages {
+		cursor := 0
+		if m.msgFor == item.ID {
+			cursor = m.msgCursor
+		}
+		elapsed := time.Duration(-1)
+		if m.replayFor == item.ID {
+			elapsed = m.replayNow.Sub(m.replayStart)
+		}
+		rightPanel = detailsStyle.Render(renderMessageView(item.LoggedRequest, cursor, elapsed))
+	} else if ok {
+		details := m.renderRequestDetails(item.LoggedRequest)
+		if len(item.hops) > 0 {
+			details += "\n\n" + renderRedirectChain(item)
+		}
+		rightPanel = detailsStyle.Render(details)
 	} else {
 		rightPanel = detailsStyle.Render("No request selected")
 	}
@@ -271,6 +545,81 @@ func (m Model) View() string {
 	return mainView
 }
 
+// moveMessageCursor selects the next or previous message of req, starting
+// over when a different request was selected
+func (m *Model) moveMessageCursor(req *models.LoggedRequest, down bool) {
+	if m.msgFor != req.ID {
+		m.msgFor, m.msgCursor = req.ID, 0
+	}
+	switch {
+	case down && m.msgCursor < len(req.Messages)-1:
+		m.msgCursor++
+	case !down && m.msgCursor > 0:
+		m.msgCursor--
+	}
+}
+
+// replayFinished reports whether the replay of req has shown every message
+func (m Model) replayFinished(req *models.LoggedRequest) bool {
+	last := req.Messages[len(req.Messages)-1]
+	return m.replayNow.Sub(m.replayStart) >= last.Offset
+}
+
+// visibleRequests returns the loaded requests that match the direction filter
+func (m Model) visibleRequests() []*models.LoggedRequest {
+	if m.direction == "" {
+		return m.requests
+	}
+
+	var visible []*models.LoggedRequest
+	for _, req := range m.requests {
+		if req.IsInbound() == (m.direction == models.DirectionInbound) {
+			visible = append(visible, req)
+		}
+	}
+	return visible
+}
+
+// listTitle names the request list after the direction filter
+func listTitle(direction string) string {
+	if direction == "" {
+		return "HTTP Requests"
+	}
+	return fmt.Sprintf("HTTP Requests (%s)", direction)
+}
+
+// selectRequest moves the list cursor to the request with the given ID
+func (m *Model) selectRequest(id string) {
+	for i, item := range m.list.Items() {
+		if req, ok := item.(requestItem); ok && req.ID == id {
+			m.list.Select(i)
+			return
+		}
+	}
+}
+
+// renderRedirectChain lists every hop of a redirect chain
+func renderRedirectChain(item requestItem) string {
+	var b strings.Builder
+
+	b.WriteString(headerStyle.Render("REDIRECT CHAIN"))
+	b.WriteString("\n\n")
+	for _, req := range append([]*models.LoggedRequest{item.LoggedRequest}, item.hops...) {
+		status := "ERROR"
+		if req.Response != nil {
+			status = fmt.Sprintf("%d", req.Response.StatusCode)
+		}
+		b.WriteString(fmt.Sprintf("%d. [%s] %s %s (%v)\n", req.RedirectIndex, status, req.Method, req.URL, req.Duration.Truncate(time.Millisecond)))
+		if req.Response != nil {
+			if location := req.Response.Headers.Get("Location"); location != "" {
+				b.WriteString(fmt.Sprintf("   → %s\n", location))
+			}
+		}
+	}
+
+	return b.String()
+}
+
 // renderRequestDetails renders the details panel content
 func (m Model) renderRequestDetails(req *models.LoggedRequest) string {
 	if req == nil {
@@ -287,31 +636,58 @@ func (m Model) renderRequestDetails(req *models.LoggedRequest) string {
 	b.WriteString(fmt.Sprintf("Timestamp: %s\n", req.Timestamp.Format("2006-01-02 15:04:05")))
 	b.WriteString(fmt.Sprintf("Duration: %v\n", req.Duration))
 	b.WriteString(fmt.Sprintf("Namespace: %s\n", req.Namespace))
+	if req.IsInbound() {
+		b.WriteString("Direction: inbound (received by server)\n")
+	}
+	if req.GRPC != nil {
+		b.WriteString(fmt.Sprintf("gRPC: %s (%s)\n", req.GRPC.FullMethod(), strings.ReplaceAll(req.GRPC.Kind, "_", " ")))
+	}
+	if len(req.Tags) > 0 {
+		b.WriteString(fmt.Sprintf("Tags: %s\n", strings.Join(req.Tags, ", ")))
+	}
 
+	if req.Network != nil {
+		b.WriteString(fmt.Sprintf("Network: %s, simulated\n", req.Network))
+	}
+	if hit := req.Breakpoint; hit != nil {
+		b.WriteString(fmt.Sprintf("Breakpoint: %s, paused %v", hit.Name, hit.Paused.Truncate(time.Millisecond)))
+		switch {
+		case hit.RequestEdited && hit.ResponseEdited:
+			b.WriteString(", request and response edited")
+		case hit.RequestEdited:
+			b.WriteString(", request edited")
+		case hit.ResponseEdited:
+			b.WriteString(", response edited")
+		}
+		b.WriteString("\n")
+	}
+	if req.Fault != nil {
+		b.WriteString(truncatedStyle.Render("Fault: injected "+req.Fault.String()) + "\n")
+	}
 	if req.Error != "" {
 		b.WriteString(fmt.Sprintf("Error: %s\n", req.Error))
 	}
+	if req.ParentID != "" {
+		b.WriteString(fmt.Sprintf("Redirect: hop %d, from %s\n", req.RedirectIndex, req.ParentID))
+	}
+	if len(req.Redacted) > 0 {
+		b.WriteString(fmt.Sprintf("Redacted: %d values\n", len(req.Redacted)))
+	}
 
 	// Request headers
 	if len(req.Headers) > 0 {
 		b.WriteString("\n")
 		b.WriteString(subHeaderStyle.Render("Request Headers:"))
 		b.WriteString("\n")
-		for k, v := range req.Headers {
-			b.WriteString(fmt.Sprintf("  %s: %s\n", k, v))
-		}
+		writeHeaders(&b, req.Headers)
 	}
 
 	// Request body
-	if req.Body != "" {
+	if req.Body != "" || req.Truncated {
 		b.WriteString("\n")
 		b.WriteString(subHeaderStyle.Render("Request Body:"))
 		b.WriteString("\n")
-		body := req.Body
-		if len(body) > 200 {
-			body = body[:200] + "..."
-		}
-		b.WriteString(body)
+		b.WriteString(renderBody(req.Body, req.BodyEncoding, req.Headers.Get("Content-Type"), req.BodySize, req.Truncated, 200))
 		b.WriteString("\n")
 	}
 
@@ -321,44 +697,257 @@ func (m Model) renderRequestDetails(req *models.LoggedRequest) string {
 		b.WriteString("\n")
 		b.WriteString(headerStyle.Render("RESPONSE"))
 		b.WriteString("\n\n")
-		b.WriteString(fmt.Sprintf("Status: %d\n", resp.StatusCode))
-		b.WriteString(fmt.Sprintf("Size: %d bytes\n", resp.Size))
+		switch {
+		case req.GRPC != nil && req.GRPC.StatusMessage != "":
+			b.WriteString(fmt.Sprintf("Status: %s (%s)\n", req.GRPC.Code, req.GRPC.StatusMessage))
+		case req.GRPC != nil:
+			b.WriteString(fmt.Sprintf("Status: %s\n", req.GRPC.Code))
+		default:
+			b.WriteString(fmt.Sprintf("Status: %d\n", resp.StatusCode))
+		}
+		switch {
+		case resp.Partial && resp.StatusCode == http.StatusSwitchingProtocols:
+			b.WriteString("Session: still open when logged\n")
+		case resp.Partial && req.IsInbound():
+			b.WriteString(fmt.Sprintf("Size: %d bytes (partial, client went away)\n", resp.Size))
+		case resp.Partial:
+			b.WriteString(fmt.Sprintf("Size: %d bytes (partial, body closed early)\n", resp.Size))
+		default:
+			b.WriteString(fmt.Sprintf("Size: %d bytes\n", resp.Size))
+		}
+		if resp.Hijacked {
+			b.WriteString("Hijacked: handler took over the connection\n")
+		}
+		if location := resp.Headers.Get("Location"); location != "" && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+			b.WriteString(fmt.Sprintf("Location: %s\n", location))
+		}
+		switch {
+		case resp.ContentEncoding != "" && resp.DecodedSize > 0:
+			b.WriteString(fmt.Sprintf("Encoding: %s (%d bytes decoded, %.1fx)\n", resp.ContentEncoding, resp.DecodedSize, resp.CompressionRatio))
+		case resp.ContentEncoding != "":
+			b.WriteString(fmt.Sprintf("Encoding: %s (decoded up to the capture limit)\n", resp.ContentEncoding))
+		}
 
 		// Response headers
 		if len(resp.Headers) > 0 {
 			b.WriteString("\n")
 			b.WriteString(subHeaderStyle.Render("Response Headers:"))
 			b.WriteString("\n")
-			for k, v := range resp.Headers {
-				b.WriteString(fmt.Sprintf("  %s: %s\n", k, v))
-			}
+			writeHeaders(&b, resp.Headers)
 		}
 
 		// Response body
-		if resp.Body != "" {
+		if resp.Body != "" || resp.Truncated {
 			b.WriteString("\n")
 			b.WriteString(subHeaderStyle.Render("Response Body:"))
 			b.WriteString("\n")
-			body := resp.Body
-			if len(body) > 300 {
-				body = body[:300] + "..."
-			}
-			b.WriteString(body)
+			b.WriteString(renderBody(resp.Body, resp.BodyEncoding, resp.Headers.Get("Content-Type"), resp.Size, resp.Truncated, 300))
+		}
+
+		if len(resp.Trailers) > 0 {
+			b.WriteString("\n")
+			b.WriteString(subHeaderStyle.Render("Response Trailers:"))
+			b.WriteString("\n")
+			writeHeaders(&b, resp.Trailers)
+		}
+	}
+
+	// Stream messages
+	if len(req.Messages) > 0 {
+		b.WriteString("\n\n")
+		b.WriteString(headerStyle.Render(fmt.Sprintf("MESSAGES (%d)", len(req.Messages))))
+		b.WriteString("\n\n")
+		b.WriteString(renderMessages(req.Messages, 3))
+		b.WriteString("Press m for the full message stream\n")
+	}
+
+	// Connection details
+	if req.Response != nil && req.Response.Connection != nil {
+		b.WriteString("\n\n")
+		b.WriteString(headerStyle.Render("CONNECTION"))
+		b.WriteString("\n\n")
+		b.WriteString(renderConnection(req.Response.Connection))
+	}
+
+	// Timing breakdown
+	if t := req.Timing; t != nil {
+		b.WriteString("\n\n")
+		b.WriteString(headerStyle.Render("TIMING"))
+		b.WriteString("\n\n")
+		b.WriteString(fmt.Sprintf("DNS Lookup:        %v\n", t.DNS))
+		b.WriteString(fmt.Sprintf("TCP Connect:       %v\n", t.Connect))
+		b.WriteString(fmt.Sprintf("TLS Handshake:     %v\n", t.TLSHandshake))
+		b.WriteString(fmt.Sprintf("Server Wait:       %v\n", t.Wait))
+		b.WriteString(fmt.Sprintf("Time to 1st Byte:  %v\n", t.TimeToFirstByte))
+		b.WriteString(fmt.Sprintf("Content Transfer:  %v\n", t.ContentTransfer))
+		b.WriteString(fmt.Sprintf("Connection Reused: %t\n", t.ConnReused))
+	}
+
+	// Make masked secrets stand out from real values
+	return strings.ReplaceAll(b.String(), models.RedactedValue, redactedStyle.Render(models.RedactedValue))
+}
+
+// renderMessages lists the first max stream messages with their timing,
+// direction and payload
+func renderMessages(messages []models.Message, max int) string {
+	var b strings.Builder
+
+	for i, msg := range messages {
+		if i == max {
+			b.WriteString(fmt.Sprintf("... %d more\n", len(messages)-max))
+			break
+		}
+
+		arrow := "←"
+		if msg.IsSent() {
+			arrow = "→"
+		}
+		b.WriteString(fmt.Sprintf("+%v %s %s", msg.Offset.Truncate(time.Millisecond), arrow, msg.Direction))
+		if msg.Type != "" {
+			b.WriteString(" " + msg.Type)
+		}
+		b.WriteString(fmt.Sprintf(" (%d bytes)\n", msg.Size))
+		b.WriteString(renderBody(msg.Data, msg.Encoding, "", msg.Size, msg.Truncated, 200))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// writeHeaders renders headers sorted by name, one line per value so repeated
+// headers such as Set-Cookie are all visible
+func writeHeaders(b *strings.Builder, headers models.Headers) {
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		for _, v := range headers[k] {
+			b.WriteString(fmt.Sprintf("  %s: %s\n", k, v))
+		}
+	}
+}
+
+// renderConnection renders protocol, TLS and certificate details, warning
+// about certificates that are expired or close to expiry
+func renderConnection(conn *models.ConnectionInfo) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("Protocol: %s\n", conn.Protocol))
+	if conn.RemoteAddr != "" {
+		b.WriteString(fmt.Sprintf("Remote: %s\n", conn.RemoteAddr))
+		b.WriteString(fmt.Sprintf("Local: %s\n", conn.LocalAddr))
+	}
+	if conn.TLSVersion == "" {
+		return b.String()
+	}
+
+	b.WriteString(fmt.Sprintf("TLS: %s\n", conn.TLSVersion))
+	b.WriteString(fmt.Sprintf("Cipher: %s\n", conn.CipherSuite))
+	if conn.ALPN != "" {
+		b.WriteString(fmt.Sprintf("ALPN: %s\n", conn.ALPN))
+	}
+	if conn.ServerName != "" {
+		b.WriteString(fmt.Sprintf("SNI: %s\n", conn.ServerName))
+	}
+
+	now := time.Now()
+	for i, cert := range conn.Certificates {
+		b.WriteString("\n")
+		b.WriteString(subHeaderStyle.Render(fmt.Sprintf("Certificate %d:", i)))
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprintf("  Subject: %s\n", cert.Subject))
+		b.WriteString(fmt.Sprintf("  Issuer: %s\n", cert.Issuer))
+		b.WriteString(fmt.Sprintf("  Expires: %s\n", cert.NotAfter.Format("2006-01-02")))
+
+		switch {
+		case cert.NotAfter.Before(now):
+			b.WriteString(truncatedStyle.Render("  ⚠ certificate has expired"))
+			b.WriteString("\n")
+		case cert.ExpiresWithin(certExpiryWarning, now):
+			days := int(cert.NotAfter.Sub(now).Hours() / 24)
+			b.WriteString(truncatedStyle.Render(fmt.Sprintf("  ⚠ certificate expires in %d days", days)))
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+// renderBody renders a stored body preview of at most max bytes. Binary
+// bodies are shown as a summary and hex dump instead of raw characters.
+func renderBody(body, encoding, contentType string, size int64, truncated bool, max int) string {
+	data, err := models.DecodeBody(body, encoding)
+	if err != nil {
+		return truncatedStyle.Render(fmt.Sprintf("[unreadable body: %v]", err))
+	}
+
+	var b strings.Builder
+	if truncated {
+		b.WriteString(truncatedMarker(len(data), size))
+		b.WriteString("\n")
+	}
+
+	if encoding == models.BodyEncodingBase64 {
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
 		}
+		b.WriteString(binaryStyle.Render(fmt.Sprintf("[binary body: %s, %d bytes]", contentType, size)))
+		b.WriteString("\n")
+
+		// Hex dumps are about four times the size of the data
+		if len(data) > max/4 {
+			data = data[:max/4]
+		}
+		b.WriteString(hex.Dump(data))
+		return b.String()
 	}
 
+	text := string(data)
+	if len(text) > max {
+		text = text[:max] + "..."
+	}
+	b.WriteString(text)
 	return b.String()
 }
 
+// truncatedMarker renders the notice shown above a body that was cut off by
+// the SDK's capture limits
+func truncatedMarker(stored int, size int64) string {
+	if stored == 0 {
+		return truncatedStyle.Render(fmt.Sprintf("[TRUNCATED: body not stored, %d bytes]", size))
+	}
+	return truncatedStyle.Render(fmt.Sprintf("[TRUNCATED: showing %d of %d bytes]", stored, size))
+}
+
 // renderHelp renders the help text
 func (m Model) renderHelp() string {
-	return `
+	help := helpText
+	if m.interceptErr != nil {
+		help += fmt.Sprintf("\nBreakpoints do not pause here: %v\n", m.interceptErr)
+	}
+	return help
+}
+
+// helpText is the static part of the help
+const helpText = `
 SLURPY - HTTP Request Logger & Debugger
 
 Key Bindings:
   ↑/k, ↓/j     Navigate up/down in request list
   ←/h, →/l     Navigate left/right (not implemented)
   tab          Switch focus between panels
+  enter        Expand/collapse a redirect chain
+  d            Cycle between all, outbound and inbound requests
+  m            Toggle the messages view for WebSocket, gRPC and event streams
+               (tab to it, then ↑/↓ to pick a message)
+  p            Replay the stream in the messages view at its recorded pace
+  g            Continue the request or response paused at a breakpoint
+  e            Edit it first: method, URL, headers and body
+               (ctrl+s saves, esc discards)
+  x            Abort it; the program gets an error
   r            Refresh requests
   c            Clear current namespace (when not viewing all)
   ?            Toggle this help
@@ -370,5 +959,6 @@ Focus:
 
 The left panel shows all HTTP requests, the right panel shows detailed
 information about the selected request, similar to browser dev tools.
+Requests paused at SDK breakpoints take over the right panel until they
+are continued or aborted.
 `
-}
@@ -65,4 +65,25 @@ var (
 	statusPendingStyle = lipgloss.NewStyle().
 				Foreground(accentColor).
 				Bold(true)
+
+	// Body styles
+	truncatedStyle = lipgloss.NewStyle().
+			Foreground(errorColor).
+			Bold(true)
+
+	redactedStyle = lipgloss.NewStyle().
+			Foreground(primaryColor).
+			Bold(true)
+
+	binaryStyle = lipgloss.NewStyle().
+			Foreground(accentColor).
+			Italic(true)
+
+	// Message view styles
+	selectedMessageStyle = lipgloss.NewStyle().
+				Foreground(accentColor).
+				Bold(true)
+
+	stallStyle = lipgloss.NewStyle().
+			Foreground(errorColor)
 )
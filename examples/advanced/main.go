@@ -29,6 +29,7 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer userClient.Close()
 
 	// Client for API gateway (different namespace)
 	gatewayClient, err := slurpy.New(slurpy.Config{
@@ -38,6 +39,7 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer gatewayClient.Close()
 
 	// Example 2: CRUD operations
 	fmt.Println("\n1. Creating user...")
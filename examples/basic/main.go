@@ -18,6 +18,8 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	// Logs are written in the background; Close makes sure they all land
+	defer client.Close()
 
 	fmt.Println("Making HTTP requests with Slurpy logging...")
 
@@ -0,0 +1,196 @@
+// Package intercept connects SDK breakpoints to the CLI. The CLI listens on a
+// unix socket in the slurpy directory; each paused request or response opens
+// a connection, sends one models.Interception as JSON and waits for one
+// models.Verdict back.
+package intercept
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/models"
+	"github.com/bobby/slurpy/pkg/storage"
+)
+
+// SocketName is the socket the CLI listens on, in the slurpy directory
+const SocketName = "intercept.sock"
+
+// readTimeout bounds how long Accept waits for a new connection to send its
+// interception
+const readTimeout = 5 * time.Second
+
+// SocketPath returns the socket location for a slurpy directory. An empty
+// baseDir uses storage.DefaultDir.
+func SocketPath(baseDir string) (string, error) {
+	if baseDir == "" {
+		dir, err := storage.DefaultDir()
+		if err != nil {
+			return "", err
+		}
+		baseDir = dir
+	}
+	return filepath.Join(baseDir, SocketName), nil
+}
+
+// Conn is a connection to a CLI, made before an exchange is paused so that
+// nothing is held up or buffered when no CLI is running
+type Conn struct {
+	conn net.Conn
+	ctx  context.Context
+	stop func() bool
+}
+
+// Connect dials the CLI listening on socket. It returns a nil Conn when no CLI
+// is listening, and the context's error if ctx is done first.
+func Connect(ctx context.Context, socket string) (*Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", socket)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	}
+
+	// Closing the connection unblocks Pause if ctx ends first
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	return &Conn{conn: conn, ctx: ctx, stop: stop}, nil
+}
+
+// Pause hands ic to the CLI and waits for its verdict, then closes the
+// connection. When the CLI goes away before answering, Pause returns a nil
+// verdict and the exchange should continue unchanged. It returns early with
+// the context's error if the context passed to Connect is done first.
+func (c *Conn) Pause(ic *models.Interception) (*models.Verdict, error) {
+	defer c.conn.Close()
+	defer c.stop()
+
+	var verdict models.Verdict
+	err := json.NewEncoder(c.conn).Encode(ic)
+	if err == nil {
+		err = json.NewDecoder(c.conn).Decode(&verdict)
+	}
+	switch {
+	case c.ctx.Err() != nil:
+		return nil, c.ctx.Err()
+	case err != nil:
+		return nil, nil
+	}
+	return &verdict, nil
+}
+
+// Listener accepts paused exchanges from SDKs
+type Listener struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	pending map[*Paused]struct{} // Accepted and still waiting
+}
+
+// Listen starts accepting paused exchanges on socket. A socket left behind by
+// a CLI that exited is replaced, but one that is still being served is not:
+// only one CLI intercepts at a time.
+func Listen(socket string) (*Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create slurpy directories: %w", err)
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another slurpy CLI is already intercepting on %s", socket)
+	}
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+	// Paused requests carry credentials, so only the owner may connect
+	if err := os.Chmod(socket, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	return &Listener{ln: ln, pending: make(map[*Paused]struct{})}, nil
+}
+
+// Accept waits for the next paused exchange. Connections that do not send a
+// valid interception are dropped.
+func (l *Listener) Accept() (*Paused, error) {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		// SDKs send the interception right after connecting
+		p := &Paused{conn: conn, gone: make(chan struct{})}
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		if err := json.NewDecoder(conn).Decode(&p.Interception); err != nil {
+			conn.Close()
+			continue
+		}
+		conn.SetReadDeadline(time.Time{})
+
+		l.mu.Lock()
+		l.pending[p] = struct{}{}
+		l.mu.Unlock()
+
+		// The SDK sends nothing more, so a read only returns once either
+		// side closes the connection
+		go func() {
+			conn.Read(make([]byte, 1))
+			close(p.gone)
+			l.mu.Lock()
+			delete(l.pending, p)
+			l.mu.Unlock()
+		}()
+		return p, nil
+	}
+}
+
+// Close stops listening and removes the socket. SDKs waiting on exchanges
+// that were never resolved continue them unchanged.
+func (l *Listener) Close() error {
+	err := l.ln.Close()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for p := range l.pending {
+		p.conn.Close()
+	}
+	return err
+}
+
+// Paused is an exchange waiting at a breakpoint for a verdict
+type Paused struct {
+	models.Interception
+
+	conn net.Conn
+	gone chan struct{}
+	once sync.Once
+}
+
+// Resolve sends the verdict to the waiting SDK. Only the first call has an
+// effect.
+func (p *Paused) Resolve(verdict models.Verdict) error {
+	err := net.ErrClosed
+	p.once.Do(func() {
+		err = json.NewEncoder(p.conn).Encode(verdict)
+		p.conn.Close()
+	})
+	return err
+}
+
+// Gone is closed once the exchange no longer waits, because it was resolved
+// or the SDK gave up on it, e.g. when the request's context was canceled
+func (p *Paused) Gone() <-chan struct{} {
+	return p.gone
+}
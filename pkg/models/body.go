@@ -0,0 +1,99 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// BodyEncodingBase64 marks a body that was stored base64-encoded because it
+// holds binary data
+const BodyEncodingBase64 = "base64"
+
+// textMediaTypes are non-text/* media types whose bodies are human-readable
+var textMediaTypes = map[string]bool{
+	"application/json":                  true,
+	"application/xml":                   true,
+	"application/javascript":            true,
+	"application/ecmascript":            true,
+	"application/x-www-form-urlencoded": true,
+	"application/graphql":               true,
+	"application/x-yaml":                true,
+	"application/yaml":                  true,
+	"application/x-ndjson":              true,
+}
+
+// EncodeBody converts captured body bytes to their stored form. Text is kept
+// as is; binary data is base64-encoded and the returned encoding says so.
+func EncodeBody(data []byte, contentType string) (body string, encoding string) {
+	if !IsBinary(data, contentType) {
+		return string(trimPartialRune(data)), ""
+	}
+	return base64.StdEncoding.EncodeToString(data), BodyEncodingBase64
+}
+
+// DecodeBody returns the original bytes of a stored body
+func DecodeBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case BodyEncodingBase64:
+		return base64.StdEncoding.DecodeString(body)
+	default:
+		return nil, fmt.Errorf("unknown body encoding %q", encoding)
+	}
+}
+
+// IsBinary reports whether a body should be treated as binary data, based on
+// its Content-Type and, when that is missing or inconclusive, its contents
+func IsBinary(data []byte, contentType string) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+
+	textual := strings.HasPrefix(mediaType, "text/") ||
+		textMediaTypes[mediaType] ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml")
+	if !textual && mediaType != "application/octet-stream" {
+		return true
+	}
+
+	// Even declared text must be valid UTF-8 to survive a JSON string
+	data = trimPartialRune(data)
+	return !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0
+}
+
+// trimPartialRune drops an incomplete rune from the end of data, which is
+// where a body cut off by the capture limit may end
+func trimPartialRune(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		if !utf8.RuneStart(data[len(data)-i]) {
+			continue
+		}
+		if !utf8.FullRune(data[len(data)-i:]) {
+			return data[:len(data)-i]
+		}
+		break
+	}
+	return data
+}
+
+// BodyBytes returns the original bytes of the request body
+func (lr *LoggedRequest) BodyBytes() ([]byte, error) {
+	return DecodeBody(lr.Body, lr.BodyEncoding)
+}
+
+// BodyBytes returns the original bytes of the response body
+func (r *LoggedResponse) BodyBytes() ([]byte, error) {
+	return DecodeBody(r.Body, r.BodyEncoding)
+}
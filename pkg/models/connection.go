@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// ConnectionInfo describes the connection a response was received on
+type ConnectionInfo struct {
+	Protocol     string            `json:"protocol"` // e.g. HTTP/1.1 or HTTP/2.0
+	RemoteAddr   string            `json:"remote_addr,omitempty"`
+	LocalAddr    string            `json:"local_addr,omitempty"`
+	TLSVersion   string            `json:"tls_version,omitempty"`
+	CipherSuite  string            `json:"cipher_suite,omitempty"`
+	ALPN         string            `json:"alpn,omitempty"`
+	ServerName   string            `json:"server_name,omitempty"`  // SNI sent by the client
+	Certificates []CertificateInfo `json:"certificates,omitempty"` // Peer chain, leaf first
+}
+
+// CertificateInfo summarizes a certificate from the peer's chain
+type CertificateInfo struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	DNSNames  []string  `json:"dns_names,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// ExpiresWithin reports whether the certificate expires less than d after now
+func (c CertificateInfo) ExpiresWithin(d time.Duration, now time.Time) bool {
+	return c.NotAfter.Before(now.Add(d))
+}
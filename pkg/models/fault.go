@@ -0,0 +1,37 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Kinds of injected fault
+const (
+	FaultLatency = "latency" // The request was delayed, then sent
+	FaultStatus  = "status"  // A synthetic response was returned instead of sending the request
+	FaultDrop    = "drop"    // The connection was reset before a response arrived
+	FaultTimeout = "timeout" // The request hung until it timed out
+)
+
+// Fault records a failure a client fault rule injected on purpose, so it can
+// be told apart from a real one
+type Fault struct {
+	Rule    string        `json:"rule"`              // Name of the rule that fired
+	Kind    string        `json:"kind"`              // FaultLatency, FaultStatus, FaultDrop or FaultTimeout
+	Latency time.Duration `json:"latency,omitempty"` // Delay added before the request was sent or failed
+	Status  int           `json:"status,omitempty"`  // Synthetic status code for FaultStatus
+}
+
+// String summarizes the fault, e.g. "status 503 after 200ms (rule orders-outage)"
+func (f *Fault) String() string {
+	what := f.Kind
+	if f.Kind == FaultStatus {
+		what = fmt.Sprintf("status %d", f.Status)
+	}
+	if f.Latency > 0 && f.Kind != FaultLatency {
+		what += fmt.Sprintf(" after %v", f.Latency)
+	} else if f.Latency > 0 {
+		what += fmt.Sprintf(" %v", f.Latency)
+	}
+	return fmt.Sprintf("%s (rule %s)", what, f.Rule)
+}
@@ -0,0 +1,24 @@
+package models
+
+// Kinds of gRPC call
+const (
+	GRPCUnary        = "unary"
+	GRPCClientStream = "client_stream"
+	GRPCServerStream = "server_stream"
+	GRPCBidiStream   = "bidi_stream"
+)
+
+// GRPCInfo describes a gRPC call. Its payloads are stored as JSON in the
+// request and response bodies for unary calls and as Messages for streams.
+type GRPCInfo struct {
+	Service       string `json:"service"`                  // e.g. "helloworld.Greeter"
+	Method        string `json:"method"`                   // e.g. "SayHello"
+	Kind          string `json:"kind"`                     // GRPCUnary or one of the stream kinds
+	Code          string `json:"code,omitempty"`           // Status code name, e.g. "OK" or "NotFound"
+	StatusMessage string `json:"status_message,omitempty"` // Status description from the server
+}
+
+// FullMethod returns the method in "/service/method" form
+func (g *GRPCInfo) FullMethod() string {
+	return "/" + g.Service + "/" + g.Method
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Stages at which a breakpoint pauses an exchange
+const (
+	StageRequest  = "request"  // Before the request is sent
+	StageResponse = "response" // Before the caller sees the response
+)
+
+// Interception is a request or response paused at a breakpoint, as the SDK
+// hands it to the CLI for inspection and editing
+type Interception struct {
+	ID           string  `json:"id"`
+	Stage        string  `json:"stage"` // StageRequest or StageResponse
+	Breakpoint   string  `json:"breakpoint"`
+	Namespace    string  `json:"namespace"`
+	Method       string  `json:"method"`
+	URL          string  `json:"url"`
+	StatusCode   int     `json:"status_code,omitempty"` // Set at StageResponse
+	Headers      Headers `json:"headers"`               // Request or response headers, by stage
+	Body         string  `json:"body,omitempty"`
+	BodyEncoding string  `json:"body_encoding,omitempty"` // BodyEncodingBase64 for binary bodies
+	BodyOmitted  bool    `json:"body_omitted,omitempty"`  // Body too large or streamed; edits to it are ignored
+}
+
+// Verdict is the CLI's answer to an Interception
+type Verdict struct {
+	Abort  bool          `json:"abort,omitempty"`  // Fail the request instead of continuing
+	Edited *Interception `json:"edited,omitempty"` // Replacement message; nil continues unchanged
+}
+
+// BreakpointHit records that an exchange was paused at a breakpoint
+type BreakpointHit struct {
+	Name           string        `json:"name"`
+	Paused         time.Duration `json:"paused"` // Time spent waiting for the CLI, included in Duration
+	RequestEdited  bool          `json:"request_edited,omitempty"`
+	ResponseEdited bool          `json:"response_edited,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Directions of a Message, as seen from the instrumented program
+const (
+	MessageSent     = "sent"
+	MessageReceived = "received"
+)
+
+// Message is one message of a streaming exchange, such as a gRPC stream
+// message, a WebSocket frame or a server-sent event
+type Message struct {
+	Direction string        `json:"direction"`           // MessageSent or MessageReceived
+	Offset    time.Duration `json:"offset"`              // Time since the exchange started
+	Type      string        `json:"type,omitempty"`      // Protocol-specific kind, e.g. a protobuf message name
+	ID        string        `json:"id,omitempty"`        // Event ID of a server-sent event
+	Size      int64         `json:"size"`                // Original size of the payload
+	Data      string        `json:"data,omitempty"`      // Payload, stored like a body
+	Encoding  string        `json:"encoding,omitempty"`  // BodyEncodingBase64 for binary payloads
+	Truncated bool          `json:"truncated,omitempty"` // Data was cut off by the capture limit
+}
+
+// IsSent reports whether the instrumented program sent the message
+func (m *Message) IsSent() bool {
+	return m.Direction == MessageSent
+}
+
+// Bytes returns the original bytes of the payload
+func (m *Message) Bytes() ([]byte, error) {
+	return DecodeBody(m.Data, m.Encoding)
+}
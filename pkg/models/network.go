@@ -0,0 +1,33 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// NetworkProfile describes a simulated network connection. Rates are in bytes
+// per second, and zero means unlimited.
+type NetworkProfile struct {
+	Name     string        `json:"name"`
+	Download int64         `json:"download,omitempty"` // Response body rate
+	Upload   int64         `json:"upload,omitempty"`   // Request body rate
+	RTT      time.Duration `json:"rtt,omitempty"`      // Latency added per round trip
+}
+
+// String summarizes the profile, e.g. "3g (↓200.0 KB/s ↑96.0 KB/s, 300ms RTT)"
+func (p *NetworkProfile) String() string {
+	return fmt.Sprintf("%s (↓%s ↑%s, %v RTT)", p.Name, formatRate(p.Download), formatRate(p.Upload), p.RTT)
+}
+
+// formatRate renders bytes per second in the largest fitting unit
+func formatRate(rate int64) string {
+	switch {
+	case rate <= 0:
+		return "unlimited"
+	case rate >= 1000*1000:
+		return fmt.Sprintf("%.1f MB/s", float64(rate)/(1000*1000))
+	case rate >= 1000:
+		return fmt.Sprintf("%.1f KB/s", float64(rate)/1000)
+	}
+	return fmt.Sprintf("%d B/s", rate)
+}
@@ -2,30 +2,76 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 )
 
+// RedactedValue replaces secrets that were masked before a request was saved
+const RedactedValue = "[REDACTED]"
+
+// Directions of a logged exchange, as seen from the instrumented program
+const (
+	DirectionOutbound = "outbound" // A request the program sent as a client
+	DirectionInbound  = "inbound"  // A request the program's server received
+)
+
 // LoggedRequest represents a complete HTTP request/response cycle
 type LoggedRequest struct {
-	ID        string            `json:"id"`
-	Timestamp time.Time         `json:"timestamp"`
-	Method    string            `json:"method"`
-	URL       string            `json:"url"`
-	Headers   map[string]string `json:"headers"`
-	Body      string            `json:"body,omitempty"`
-	Response  *LoggedResponse   `json:"response,omitempty"`
-	Duration  time.Duration     `json:"duration"`
-	Namespace string            `json:"namespace"`
-	Error     string            `json:"error,omitempty"`
+	ID              string          `json:"id"`
+	Timestamp       time.Time       `json:"timestamp"`
+	Method          string          `json:"method"`
+	URL             string          `json:"url"`
+	Headers         Headers         `json:"headers"`
+	Body            string          `json:"body,omitempty"`
+	BodyEncoding    string          `json:"body_encoding,omitempty"` // BodyEncodingBase64 for binary bodies
+	BodySize        int64           `json:"body_size,omitempty"`     // Original size of the request body
+	Truncated       bool            `json:"body_truncated,omitempty"`
+	Response        *LoggedResponse `json:"response,omitempty"`
+	Duration        time.Duration   `json:"duration"`
+	Timing          *Timing         `json:"timing,omitempty"`
+	Namespace       string          `json:"namespace"`
+	Direction       string          `json:"direction,omitempty"` // DirectionOutbound or DirectionInbound
+	Tags            []string        `json:"tags,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	Redacted        []string        `json:"redacted,omitempty"`         // Locations of values replaced with RedactedValue
+	Messages        []Message       `json:"messages,omitempty"`         // Stream messages in arrival order
+	MessagesDropped int64           `json:"messages_dropped,omitempty"` // Messages beyond the storage cap
+	GRPC            *GRPCInfo       `json:"grpc,omitempty"`             // Set for gRPC calls
+	Fault           *Fault          `json:"fault,omitempty"`            // Set when a fault rule was applied
+	Network         *NetworkProfile `json:"network,omitempty"`          // Simulated network the exchange went through
+	Breakpoint      *BreakpointHit  `json:"breakpoint,omitempty"`       // Set when the exchange was paused at a breakpoint
+
+	// Redirect hops link to the exchange whose response redirected them
+	ParentID      string `json:"parent_id,omitempty"`
+	RedirectIndex int    `json:"redirect_index,omitempty"` // 0 for the original request
+}
+
+// IsInbound reports whether the exchange was received by a server rather
+// than sent by a client. Logs from before directions were recorded are
+// outbound.
+func (lr *LoggedRequest) IsInbound() bool {
+	return lr.Direction == DirectionInbound
 }
 
 // LoggedResponse represents the HTTP response
 type LoggedResponse struct {
-	StatusCode int               `json:"status_code"`
-	Headers    map[string]string `json:"headers"`
-	Body       string            `json:"body,omitempty"`
-	Size       int64             `json:"size"`
+	StatusCode   int     `json:"status_code"`
+	Headers      Headers `json:"headers"`
+	Body         string  `json:"body,omitempty"`
+	BodyEncoding string  `json:"body_encoding,omitempty"` // BodyEncodingBase64 for binary bodies
+	Size         int64   `json:"size"`                    // Original size of the response body
+	Truncated    bool    `json:"body_truncated,omitempty"`
+	Partial      bool    `json:"partial,omitempty"`  // Caller closed the body before reading it all
+	Hijacked     bool    `json:"hijacked,omitempty"` // Server handler took over the connection
+
+	// Set when the body was decoded from its Content-Encoding for display
+	ContentEncoding  string  `json:"content_encoding,omitempty"`
+	DecodedSize      int64   `json:"decoded_size,omitempty"`      // Zero when decoding stopped at the capture limit
+	CompressionRatio float64 `json:"compression_ratio,omitempty"` // Decoded size over wire size
+
+	Trailers   Headers         `json:"trailers,omitempty"`
+	Connection *ConnectionInfo `json:"connection,omitempty"`
 }
 
 // RequestLog represents a collection of logged requests for a namespace
@@ -48,12 +94,48 @@ func FromJSON(data []byte) (*LoggedRequest, error) {
 	return &req, err
 }
 
-// HeadersFromHTTP converts http.Header to map[string]string
-func HeadersFromHTTP(h http.Header) map[string]string {
-	headers := make(map[string]string)
+// Headers holds every value of each header, in the order they were sent
+type Headers map[string][]string
+
+// Get returns the first value of a header, or "" if it is not set
+func (h Headers) Get(key string) string {
+	if v := h[http.CanonicalHeaderKey(key)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// UnmarshalJSON decodes headers, accepting the single-value format written by
+// older versions of slurpy
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	headers := make(Headers, len(raw))
+	for k, v := range raw {
+		var values []string
+		if err := json.Unmarshal(v, &values); err != nil {
+			var single string
+			if err := json.Unmarshal(v, &single); err != nil {
+				return fmt.Errorf("invalid value for header %s: %w", k, err)
+			}
+			values = []string{single}
+		}
+		headers[k] = values
+	}
+
+	*h = headers
+	return nil
+}
+
+// HeadersFromHTTP copies http.Header, keeping every value
+func HeadersFromHTTP(h http.Header) Headers {
+	headers := make(Headers, len(h))
 	for k, v := range h {
 		if len(v) > 0 {
-			headers[k] = v[0] // Take first value for simplicity
+			headers[k] = append([]string(nil), v...)
 		}
 	}
 	return headers
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Timing breaks the duration of an exchange down into its phases.
+// Phases that did not happen, such as DNS on a reused connection, are zero.
+type Timing struct {
+	DNS             time.Duration `json:"dns,omitempty"`
+	Connect         time.Duration `json:"connect,omitempty"`
+	TLSHandshake    time.Duration `json:"tls_handshake,omitempty"`
+	Wait            time.Duration `json:"wait,omitempty"`               // Server think time, from request written to first byte
+	TimeToFirstByte time.Duration `json:"time_to_first_byte,omitempty"` // From request start to first response byte
+	ContentTransfer time.Duration `json:"content_transfer,omitempty"`   // From first response byte to end of body
+	ConnReused      bool          `json:"conn_reused"`
+}
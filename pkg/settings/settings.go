@@ -0,0 +1,249 @@
+// Package settings loads slurpy's layered configuration: the config file,
+// then a per-namespace profile from it, then SLURPY_* environment variables.
+// Both the SDK and the CLI read it, so a deployed binary can be switched to
+// capturing without a rebuild.
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bobby/slurpy/pkg/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the config file read from the slurpy directory
+const FileName = "config.yaml"
+
+// Environment variables, each overriding the matching config file setting
+const (
+	EnvConfig                = "SLURPY_CONFIG" // Path to the config file
+	EnvEnabled               = "SLURPY_ENABLED"
+	EnvNamespace             = "SLURPY_NAMESPACE"
+	EnvStoreDir              = "SLURPY_STORE_DIR"
+	EnvMode                  = "SLURPY_MODE"
+	EnvNetwork               = "SLURPY_NETWORK" // Profile name or "down=,up=,rtt="
+	EnvMaxRequestBody        = "SLURPY_MAX_REQUEST_BODY"
+	EnvMaxResponseBody       = "SLURPY_MAX_RESPONSE_BODY"
+	EnvRedactDisableDefaults = "SLURPY_REDACT_DISABLE_DEFAULTS"
+	EnvRedactHeaders         = "SLURPY_REDACT_HEADERS"      // Comma-separated
+	EnvRedactQueryParams     = "SLURPY_REDACT_QUERY_PARAMS" // Comma-separated
+	EnvRedactJSONPaths       = "SLURPY_REDACT_JSON_PATHS"   // Comma-separated
+)
+
+// Profile is one layer of settings. Nil and empty fields are unset and leave
+// the layer below in place.
+type Profile struct {
+	Enabled         *bool  `yaml:"enabled"`
+	Namespace       string `yaml:"namespace"`
+	StoreDir        string `yaml:"store_dir"`         // Directory holding the logs folder
+	Mode            string `yaml:"mode"`              // record, replay, record-missing or passthrough
+	Network         string `yaml:"network"`           // Simulated network profile, e.g. "3g"
+	MaxRequestBody  *int64 `yaml:"max_request_body"`  // Bytes; negative means unlimited
+	MaxResponseBody *int64 `yaml:"max_response_body"` // Bytes; negative means unlimited
+	Redact          Redact `yaml:"redact"`
+}
+
+// Redact mirrors the SDK's redaction options. Lists from every layer are
+// combined, so a layer can add rules but never remove them.
+type Redact struct {
+	DisableDefaults *bool    `yaml:"disable_defaults"`
+	Headers         []string `yaml:"headers"`
+	QueryParams     []string `yaml:"query_params"`
+	JSONPaths       []string `yaml:"json_paths"`
+	Patterns        []string `yaml:"patterns"`
+}
+
+// File is the parsed config file: top-level settings plus profiles keyed by
+// namespace
+type File struct {
+	Profile  `yaml:",inline"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Path returns the config file location, $SLURPY_CONFIG or
+// ~/.config/slurpy/config.yaml
+func Path() (string, error) {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path, nil
+	}
+	dir, err := storage.DefaultDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// ReadFile parses the config file at path. A missing file is not an error and
+// yields an empty File.
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// FromEnv reads the SLURPY_* environment variables
+func FromEnv() (Profile, error) {
+	var p Profile
+	var err error
+
+	if p.Enabled, err = envBool(EnvEnabled); err != nil {
+		return p, err
+	}
+	p.Namespace = os.Getenv(EnvNamespace)
+	p.StoreDir = os.Getenv(EnvStoreDir)
+	p.Mode = os.Getenv(EnvMode)
+	p.Network = os.Getenv(EnvNetwork)
+	if p.MaxRequestBody, err = envInt(EnvMaxRequestBody); err != nil {
+		return p, err
+	}
+	if p.MaxResponseBody, err = envInt(EnvMaxResponseBody); err != nil {
+		return p, err
+	}
+	if p.Redact.DisableDefaults, err = envBool(EnvRedactDisableDefaults); err != nil {
+		return p, err
+	}
+	p.Redact.Headers = envList(EnvRedactHeaders)
+	p.Redact.QueryParams = envList(EnvRedactQueryParams)
+	p.Redact.JSONPaths = envList(EnvRedactJSONPaths)
+	return p, nil
+}
+
+// Load resolves the file and environment layers for a namespace. The
+// namespace picks the profile; when empty, $SLURPY_NAMESPACE and then the
+// file's top-level namespace are used instead. Without $SLURPY_CONFIG or a
+// home directory, as in many containers and services, the file layer is
+// empty.
+func Load(namespace string) (Profile, error) {
+	file := &File{}
+	if path, err := Path(); err == nil {
+		if file, err = ReadFile(path); err != nil {
+			return Profile{}, err
+		}
+	}
+	env, err := FromEnv()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	if namespace == "" {
+		namespace = env.Namespace
+	}
+	if namespace == "" {
+		namespace = file.Namespace
+	}
+
+	resolved := file.Profile
+	if profile, ok := file.Profiles[namespace]; ok {
+		resolved = resolved.Merge(profile)
+	}
+	resolved = resolved.Merge(env)
+	resolved.Namespace = namespace
+
+	if resolved.StoreDir, err = expandHome(resolved.StoreDir); err != nil {
+		return Profile{}, err
+	}
+	return resolved, nil
+}
+
+// Merge returns p with every field set in over applied on top
+func (p Profile) Merge(over Profile) Profile {
+	if over.Enabled != nil {
+		p.Enabled = over.Enabled
+	}
+	if over.Namespace != "" {
+		p.Namespace = over.Namespace
+	}
+	if over.StoreDir != "" {
+		p.StoreDir = over.StoreDir
+	}
+	if over.Mode != "" {
+		p.Mode = over.Mode
+	}
+	if over.Network != "" {
+		p.Network = over.Network
+	}
+	if over.MaxRequestBody != nil {
+		p.MaxRequestBody = over.MaxRequestBody
+	}
+	if over.MaxResponseBody != nil {
+		p.MaxResponseBody = over.MaxResponseBody
+	}
+
+	if over.Redact.DisableDefaults != nil {
+		p.Redact.DisableDefaults = over.Redact.DisableDefaults
+	}
+	p.Redact.Headers = concat(p.Redact.Headers, over.Redact.Headers)
+	p.Redact.QueryParams = concat(p.Redact.QueryParams, over.Redact.QueryParams)
+	p.Redact.JSONPaths = concat(p.Redact.JSONPaths, over.Redact.JSONPaths)
+	p.Redact.Patterns = concat(p.Redact.Patterns, over.Redact.Patterns)
+	return p
+}
+
+// concat appends b to a copy of a, so merged profiles never share arrays
+func concat(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	return append(a[:len(a):len(a)], b...)
+}
+
+func envBool(name string) (*bool, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: expected true or false", name, raw)
+	}
+	return &v, nil
+}
+
+func envInt(name string) (*int64, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: expected a number of bytes", name, raw)
+	}
+	return &v, nil
+}
+
+func envList(name string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// expandHome resolves a leading ~ in a configured path
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
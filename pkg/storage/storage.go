@@ -21,14 +21,31 @@ type Storage struct {
 	baseDir string
 }
 
-// New creates a new Storage instance
+// New creates a new Storage instance in ~/.config/slurpy
 func New() (*Storage, error) {
+	return NewWithDir("")
+}
+
+// DefaultDir returns the default storage directory, ~/.config/slurpy
+func DefaultDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, SlurpyDir), nil
+}
+
+// NewWithDir creates a Storage instance rooted at baseDir, with logs kept in
+// its logs subdirectory. An empty baseDir uses DefaultDir.
+func NewWithDir(baseDir string) (*Storage, error) {
+	if baseDir == "" {
+		dir, err := DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+		baseDir = dir
 	}
 
-	baseDir := filepath.Join(homeDir, SlurpyDir)
 	if err := os.MkdirAll(filepath.Join(baseDir, LogsSubdir), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create slurpy directories: %w", err)
 	}
@@ -0,0 +1,324 @@
+package slurpy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/intercept"
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// maxInterceptBody is the largest body shown for editing at a breakpoint.
+// Larger bodies pass through untouched.
+const maxInterceptBody = 1 << 20 // 1 MiB
+
+// Breakpoint pauses matching requests so they can be inspected and edited in
+// a running slurpy CLI, like a breakpoint in browser dev tools. The CLI can
+// change the method, URL, headers and body and then continue, or abort with
+// an *AbortError. When no CLI is running, requests continue without pausing.
+type Breakpoint struct {
+	// Name identifies the breakpoint in the CLI. Defaults to "#N",
+	// counting from 1.
+	Name string
+
+	// Host, Path and Method select requests as in FaultRule. Empty fields
+	// match every request.
+	Host   string
+	Path   string
+	Method string
+
+	// Request pauses before the request is sent and Response before the
+	// caller sees the response. Leaving both false pauses on the request.
+	Request  bool
+	Response bool
+}
+
+// pausesRequest reports whether the breakpoint stops requests
+func (b *Breakpoint) pausesRequest() bool {
+	return b.Request || !b.Response
+}
+
+// compileBreakpoints validates breakpoints and fills in their names. The
+// breakpoints are copied so the caller may reuse the slice.
+func compileBreakpoints(breakpoints []Breakpoint) ([]Breakpoint, error) {
+	compiled := make([]Breakpoint, len(breakpoints))
+	for i, bp := range breakpoints {
+		if bp.Name == "" {
+			bp.Name = fmt.Sprintf("#%d", i+1)
+		}
+		for _, pattern := range []string{bp.Host, bp.Path} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q in breakpoint %q: %w", pattern, bp.Name, err)
+			}
+		}
+		compiled[i] = bp
+	}
+	return compiled, nil
+}
+
+// pickBreakpoint returns the first breakpoint that matches req, or nil
+func pickBreakpoint(breakpoints []Breakpoint, req *http.Request) *Breakpoint {
+	for i := range breakpoints {
+		bp := &breakpoints[i]
+		if matchRequest(bp.Host, bp.Path, bp.Method, req) {
+			return bp
+		}
+	}
+	return nil
+}
+
+// AbortError is returned for a request aborted from the CLI while it was
+// paused at a breakpoint
+type AbortError struct {
+	Breakpoint string
+	Stage      string // models.StageRequest or models.StageResponse
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("slurpy: %s aborted at breakpoint %s", e.Stage, e.Breakpoint)
+}
+
+// pause carries one exchange through a breakpoint. A nil pause lets
+// everything through, so callers need no checks.
+type pause struct {
+	bp        *Breakpoint
+	ctx       context.Context
+	socket    string
+	id        string
+	namespace string
+	method    string // Request as sent, for context at the response stage
+	url       string
+	hit       *models.BreakpointHit // Set once the CLI answered
+}
+
+// newPause starts tracking req at bp, or returns nil when bp is nil
+func newPause(bp *Breakpoint, req *http.Request, socket, namespace string) *pause {
+	if bp == nil {
+		return nil
+	}
+	return &pause{
+		bp:        bp,
+		ctx:       req.Context(),
+		socket:    socket,
+		id:        generateID(),
+		namespace: namespace,
+		method:    req.Method,
+		url:       req.URL.String(),
+	}
+}
+
+// request pauses req if the breakpoint stops requests and returns the request
+// to send, as edited in the CLI. Like any RoundTripper, it never modifies req
+// itself.
+func (p *pause) request(req *http.Request) (*http.Request, error) {
+	if p == nil || !p.bp.pausesRequest() {
+		return req, nil
+	}
+	// Nothing is read ahead unless a CLI is there to see it
+	conn, err := intercept.Connect(p.ctx, p.socket)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	if conn == nil {
+		return req, nil
+	}
+
+	ic := p.interception(models.StageRequest)
+	ic.Headers = models.HeadersFromHTTP(req.Header)
+	out := req.Clone(p.ctx)
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+	case req.ContentLength <= 0:
+		// A body of unknown length may be a pipe that is still being written
+		ic.BodyOmitted = true
+	default:
+		out.Body = holdBody(req.Body, req.Header.Get("Content-Type"), ic)
+	}
+
+	verdict, err := p.wait(conn, ic)
+	if err != nil {
+		closeRequestBody(out)
+		return nil, err
+	}
+	if verdict == nil || verdict.Edited == nil {
+		return out, nil
+	}
+
+	edited := verdict.Edited
+	u, err := url.Parse(edited.URL)
+	if err != nil || !u.IsAbs() {
+		closeRequestBody(out)
+		return nil, fmt.Errorf("invalid URL %q edited at breakpoint %s: expected an absolute URL", edited.URL, p.bp.Name)
+	}
+	out.Method, out.URL, out.Host = edited.Method, u, u.Host
+	out.Header = httpHeader(edited.Headers)
+	if !ic.BodyOmitted {
+		data, err := models.DecodeBody(edited.Body, edited.BodyEncoding)
+		if err != nil {
+			closeRequestBody(out)
+			return nil, fmt.Errorf("failed to decode body edited at breakpoint %s: %w", p.bp.Name, err)
+		}
+		out.Body, out.ContentLength = http.NoBody, int64(len(data))
+		out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+		if len(data) > 0 {
+			out.Body, _ = out.GetBody()
+		}
+	}
+
+	p.method, p.url = out.Method, out.URL.String()
+	p.hit.RequestEdited = true
+	return out, nil
+}
+
+// response pauses the response to the request if the breakpoint stops
+// responses, and returns it as edited in the CLI. Errors pass through.
+func (p *pause) response(resp *http.Response, err error) (*http.Response, error) {
+	if p == nil || !p.bp.Response || err != nil {
+		return resp, err
+	}
+	conn, err := intercept.Connect(p.ctx, p.socket)
+	if err != nil {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		return nil, err
+	}
+	if conn == nil {
+		return resp, nil
+	}
+
+	ic := p.interception(models.StageResponse)
+	ic.StatusCode = resp.StatusCode
+	ic.Headers = models.HeadersFromHTTP(resp.Header)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case resp.Body == nil || resp.Body == http.NoBody:
+	case resp.StatusCode == http.StatusSwitchingProtocols || mediaType == "text/event-stream" || resp.ContentLength < 0:
+		// Reading ahead would hold up a stream, such as a long poll or
+		// NDJSON, until the server sends more
+		ic.BodyOmitted = true
+	default:
+		resp.Body = holdBody(resp.Body, resp.Header.Get("Content-Type"), ic)
+	}
+
+	verdict, err := p.wait(conn, ic)
+	if err != nil {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		return nil, err
+	}
+	if verdict == nil || verdict.Edited == nil {
+		return resp, nil
+	}
+
+	edited := verdict.Edited
+	if edited.StatusCode < 100 || edited.StatusCode > 999 {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		return nil, fmt.Errorf("invalid status %d edited at breakpoint %s", edited.StatusCode, p.bp.Name)
+	}
+	resp.StatusCode = edited.StatusCode
+	resp.Status = fmt.Sprintf("%d %s", edited.StatusCode, http.StatusText(edited.StatusCode))
+	resp.Header = httpHeader(edited.Headers)
+	if !ic.BodyOmitted {
+		data, err := models.DecodeBody(edited.Body, edited.BodyEncoding)
+		if err != nil {
+			if resp.Body != nil {
+				resp.Body.Close()
+			}
+			return nil, fmt.Errorf("failed to decode body edited at breakpoint %s: %w", p.bp.Name, err)
+		}
+		resp.Body, resp.ContentLength, resp.TransferEncoding = io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+		if resp.Header.Get("Content-Length") != "" {
+			resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+		}
+	}
+
+	p.hit.ResponseEdited = true
+	return resp, nil
+}
+
+// interception describes the exchange at a stage, without headers or body
+func (p *pause) interception(stage string) *models.Interception {
+	return &models.Interception{
+		ID:         p.id,
+		Stage:      stage,
+		Breakpoint: p.bp.Name,
+		Namespace:  p.namespace,
+		Method:     p.method,
+		URL:        p.url,
+	}
+}
+
+// wait hands ic to the CLI on conn and returns its verdict, or nil when the
+// CLI did not answer. An abort is returned as an *AbortError.
+func (p *pause) wait(conn *intercept.Conn, ic *models.Interception) (*models.Verdict, error) {
+	start := time.Now()
+	verdict, err := conn.Pause(ic)
+	if err != nil || verdict == nil {
+		return nil, err
+	}
+
+	if p.hit == nil {
+		p.hit = &models.BreakpointHit{Name: p.bp.Name}
+	}
+	p.hit.Paused += time.Since(start)
+	if verdict.Abort {
+		return nil, &AbortError{Breakpoint: p.bp.Name, Stage: ic.Stage}
+	}
+	return verdict, nil
+}
+
+// record describes the pause for the logged request. Nothing is recorded
+// unless a CLI answered.
+func (p *pause) record() *models.BreakpointHit {
+	if p == nil {
+		return nil
+	}
+	return p.hit
+}
+
+// holdBody reads body into ic so it can be edited, and returns a body that
+// replays it. A body over maxInterceptBody, or one that fails to read, is
+// left out of ic and replayed from what was read followed by the rest.
+func holdBody(body io.ReadCloser, contentType string, ic *models.Interception) io.ReadCloser {
+	data, err := io.ReadAll(io.LimitReader(body, maxInterceptBody+1))
+	if err != nil || len(data) > maxInterceptBody {
+		ic.BodyOmitted = true
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), body), body}
+	}
+	body.Close()
+
+	// Editing must not lose bytes, which storing a partial rune as text would
+	ic.Body, ic.BodyEncoding = models.EncodeBody(data, contentType)
+	if ic.BodyEncoding == "" && len(ic.Body) != len(data) {
+		ic.Body, ic.BodyEncoding = base64.StdEncoding.EncodeToString(data), models.BodyEncodingBase64
+	}
+	return io.NopCloser(bytes.NewReader(data))
+}
+
+// httpHeader converts headers edited in the CLI, canonicalizing their names
+func httpHeader(headers models.Headers) http.Header {
+	h := make(http.Header, len(headers))
+	for k, values := range headers {
+		for _, v := range values {
+			h.Add(k, v)
+		}
+	}
+	return h
+}
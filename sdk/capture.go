@@ -0,0 +1,172 @@
+package slurpy
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxBodySize is the number of body bytes stored per request or
+// response when Config leaves the limit unset
+const DefaultMaxBodySize = 1 << 20 // 1 MiB
+
+// BodyRule overrides the capture limit for bodies of a given content type
+type BodyRule struct {
+	ContentType string // Media type pattern such as "application/json" or "video/*"
+	MaxSize     int64  // Bytes to store; zero uses the Config limit and negative means unlimited
+	Skip        bool   // Store no body bytes at all, only the size
+}
+
+// captureLimit returns how many body bytes to store for the given content type
+func captureLimit(contentType string, max int64, rules []BodyRule) int64 {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	for _, rule := range rules {
+		if ok, _ := path.Match(strings.ToLower(rule.ContentType), mediaType); !ok {
+			continue
+		}
+		if rule.Skip {
+			return 0
+		}
+		if rule.MaxSize != 0 {
+			return rule.MaxSize
+		}
+		break
+	}
+
+	switch {
+	case max == 0:
+		return DefaultMaxBodySize
+	case max < 0:
+		return -1
+	}
+	return max
+}
+
+// captureBuffer records the bytes flowing through a body, keeping at most
+// limit bytes while still counting the full size. A negative limit keeps
+// everything. It is safe for concurrent use, since the transport may write
+// the request body on its own goroutine while the caller finishes the exchange.
+type captureBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	size  int64
+	limit int64
+}
+
+// Write records p and never fails
+func (b *captureBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keep := p
+	if b.limit >= 0 {
+		room := b.limit - int64(b.buf.Len())
+		if room < 0 {
+			room = 0
+		}
+		if int64(len(keep)) > room {
+			keep = keep[:room]
+		}
+	}
+
+	b.buf.Write(keep)
+	b.size += int64(len(p))
+	return len(p), nil
+}
+
+// Bytes returns a copy of the captured bytes
+func (b *captureBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// Size returns the number of bytes that flowed through the body
+func (b *captureBuffer) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.size
+}
+
+// Truncated reports whether more bytes flowed through than were kept
+func (b *captureBuffer) Truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.size > int64(b.buf.Len())
+}
+
+// teeBody copies everything read from a request body into a captureBuffer
+type teeBody struct {
+	body io.ReadCloser
+	buf  *captureBuffer
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	return b.body.Close()
+}
+
+// captureBody wraps a response body so the caller can stream it normally while
+// the bytes are recorded. The exchange is finished exactly once, when the body
+// reaches EOF, fails, or is closed early.
+type captureBody struct {
+	body     io.ReadCloser
+	buf      captureBuffer
+	expected int64 // Content-Length, or -1 if unknown
+	exchange *exchange
+	stream   *streamRecorder // Set for event streams and chunked bodies
+	once     sync.Once
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.buf.Write(p[:n])
+		if b.stream != nil {
+			b.stream.write(p[:n])
+		}
+	}
+
+	if err == io.EOF {
+		b.finish(false, nil)
+	} else if err != nil {
+		b.finish(true, err)
+	}
+	return n, err
+}
+
+func (b *captureBody) Close() error {
+	err := b.body.Close()
+
+	// Callers commonly stop at Content-Length without seeing io.EOF, which is
+	// still a complete body.
+	partial := b.expected < 0 || b.buf.Size() < b.expected
+	b.finish(partial, nil)
+	return err
+}
+
+func (b *captureBody) finish(partial bool, err error) {
+	b.once.Do(func() {
+		if b.stream != nil {
+			b.exchange.logged.Messages, b.exchange.logged.MessagesDropped = b.stream.finish()
+		}
+		b.exchange.finishResponse(&b.buf, partial, err)
+	})
+}
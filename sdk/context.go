@@ -0,0 +1,58 @@
+package slurpy
+
+import "context"
+
+// contextKey identifies per-request overrides stored in a context
+type contextKey int
+
+const (
+	tagsKey contextKey = iota
+	namespaceKey
+	noBodyKey
+	skipKey
+)
+
+// WithTags returns a context whose requests are logged with the given tags,
+// in addition to any tags already on ctx
+func WithTags(ctx context.Context, tags ...string) context.Context {
+	existing, _ := ctx.Value(tagsKey).([]string)
+	merged := make([]string, 0, len(existing)+len(tags))
+	merged = append(merged, existing...)
+	merged = append(merged, tags...)
+	return context.WithValue(ctx, tagsKey, merged)
+}
+
+// WithNamespace returns a context whose requests are logged under namespace
+// instead of the client's namespace
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey, namespace)
+}
+
+// WithoutBodyCapture returns a context whose requests are logged without
+// storing request or response bodies. Sizes are still recorded.
+func WithoutBodyCapture(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noBodyKey, true)
+}
+
+// Skip returns a context whose requests are not logged at all
+func Skip(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipKey, true)
+}
+
+// requestOptions holds the per-request overrides found on a context
+type requestOptions struct {
+	tags      []string
+	namespace string
+	noBody    bool
+	skip      bool
+}
+
+// optionsFromContext reads the per-request overrides from ctx
+func optionsFromContext(ctx context.Context) requestOptions {
+	var opts requestOptions
+	opts.tags, _ = ctx.Value(tagsKey).([]string)
+	opts.namespace, _ = ctx.Value(namespaceKey).(string)
+	opts.noBody, _ = ctx.Value(noBodyKey).(bool)
+	opts.skip, _ = ctx.Value(skipKey).(bool)
+	return opts
+}
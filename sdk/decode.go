@@ -0,0 +1,79 @@
+package slurpy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// maxDecodedBody bounds decoding when the capture limit is unlimited, so a
+// decompression bomb cannot exhaust memory on the caller's goroutine
+const maxDecodedBody = 64 << 20 // 64 MiB
+
+// decodeContent undoes the Content-Encoding of a captured body so it can be
+// displayed. It stops after limit decoded bytes, or maxDecodedBody when limit
+// is negative, and returns the decoded size, or -1 if decoding stopped before
+// the end. A body cut off by the capture limit decodes as far as its bytes
+// allow.
+func decodeContent(contentEncoding string, data []byte, limit int64) ([]byte, int64, error) {
+	// Encodings are listed in the order they were applied
+	encodings := strings.Split(contentEncoding, ",")
+
+	var r io.Reader = bytes.NewReader(data)
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+
+		var err error
+		switch encoding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(r)
+		case "deflate":
+			r, err = newDeflateReader(r)
+		case "br":
+			r = brotli.NewReader(r)
+		default:
+			return nil, 0, fmt.Errorf("unsupported content encoding %q", encoding)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	max := limit
+	if max < 0 {
+		max = maxDecodedBody
+	}
+	decoded, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err == io.ErrUnexpectedEOF && len(decoded) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(decoded)) > max {
+		return decoded[:max], -1, nil
+	}
+	return decoded, int64(len(decoded)), nil
+}
+
+// newDeflateReader handles both zlib-wrapped deflate, which the spec requires,
+// and the raw deflate streams some servers send instead
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	peek := make([]byte, 2)
+	n, _ := io.ReadFull(r, peek)
+	r = io.MultiReader(bytes.NewReader(peek[:n]), r)
+
+	// A zlib header is a multiple of 31 when read as a big-endian uint16
+	if n == 2 && peek[0]&0x0f == 8 && (uint16(peek[0])<<8|uint16(peek[1]))%31 == 0 {
+		return zlib.NewReader(r)
+	}
+	return flate.NewReader(r), nil
+}
@@ -0,0 +1,263 @@
+package slurpy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// FaultRule makes matching requests misbehave on purpose, to test how a
+// program copes with a failing dependency. Requests hit by a rule are marked
+// with a models.Fault when they are logged.
+type FaultRule struct {
+	// Name identifies the rule on logged requests. Defaults to "#N",
+	// counting from 1.
+	Name string
+
+	// Host and Path are patterns as in path.Match, such as "*.example.com"
+	// or "/orders/*". Method is compared case-insensitively. Empty fields
+	// match every request.
+	Host   string
+	Path   string
+	Method string
+
+	// Probability that a matching request is hit, from 0 to 1, as made by
+	// the Probability function. Nil hits every matching request and zero
+	// none.
+	Probability *float64
+
+	// Latency delays the request before it is sent or fails
+	Latency time.Duration
+
+	// At most one of the following replaces the real response
+	Status  int               // Answer with this status without sending the request
+	Body    string            // Body of the Status response
+	Headers map[string]string // Headers of the Status response, e.g. Retry-After
+	Drop    bool              // Fail as if the connection was reset
+	Timeout time.Duration     // Hang this long, then fail with a timeout error
+}
+
+// Probability returns p for FaultRule.Probability
+func Probability(p float64) *float64 {
+	return &p
+}
+
+// compileFaults validates rules and fills in their names. The rules are
+// copied so the caller may reuse the slice.
+func compileFaults(rules []FaultRule) ([]FaultRule, error) {
+	compiled := make([]FaultRule, len(rules))
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		for _, pattern := range []string{rule.Host, rule.Path} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q in fault rule %q: %w", pattern, rule.Name, err)
+			}
+		}
+		if p := rule.Probability; p != nil {
+			if *p < 0 || *p > 1 {
+				return nil, fmt.Errorf("invalid probability %v in fault rule %q: expected 0 to 1", *p, rule.Name)
+			}
+			rule.Probability = Probability(*p)
+		}
+		if rule.Status != 0 && (rule.Status < 100 || rule.Status > 999) {
+			return nil, fmt.Errorf("invalid status %d in fault rule %q", rule.Status, rule.Name)
+		}
+
+		outcomes := 0
+		for _, set := range []bool{rule.Status != 0, rule.Drop, rule.Timeout > 0} {
+			if set {
+				outcomes++
+			}
+		}
+		switch {
+		case outcomes > 1:
+			return nil, fmt.Errorf("invalid fault rule %q: set only one of Status, Drop and Timeout", rule.Name)
+		case outcomes == 0 && rule.Latency <= 0:
+			return nil, fmt.Errorf("invalid fault rule %q: set Latency, Status, Drop or Timeout", rule.Name)
+		}
+		compiled[i] = rule
+	}
+	return compiled, nil
+}
+
+// pickFault returns the first rule that matches req and fires, or nil
+func pickFault(rules []FaultRule, req *http.Request) *FaultRule {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.matches(req) && rule.fires() {
+			return rule
+		}
+	}
+	return nil
+}
+
+// fires decides whether a matching request is hit
+func (r *FaultRule) fires() bool {
+	return r.Probability == nil || rand.Float64() < *r.Probability
+}
+
+// matches reports whether the rule covers req
+func (r *FaultRule) matches(req *http.Request) bool {
+	return matchRequest(r.Host, r.Path, r.Method, req)
+}
+
+// matchRequest reports whether req matches host and path patterns, as in
+// path.Match, and a method compared case-insensitively. Empty fields match
+// every request.
+func matchRequest(host, pathPattern, method string, req *http.Request) bool {
+	if method != "" && !strings.EqualFold(method, req.Method) {
+		return false
+	}
+	if host != "" {
+		pattern := strings.ToLower(host)
+		byName, _ := path.Match(pattern, strings.ToLower(req.URL.Hostname()))
+		byHost, _ := path.Match(pattern, strings.ToLower(req.URL.Host))
+		if !byName && !byHost {
+			return false
+		}
+	}
+	if pathPattern != "" {
+		p := req.URL.Path
+		if p == "" {
+			p = "/"
+		}
+		if ok, _ := path.Match(pathPattern, p); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// answers reports whether the fault replaces the response, so the request is
+// never sent. A nil rule answers nothing.
+func (r *FaultRule) answers() bool {
+	return r != nil && (r.Status != 0 || r.Drop || r.Timeout > 0)
+}
+
+// send applies the fault to req and, unless it replaces the response, sends
+// req with base. A nil rule just sends the request.
+func (r *FaultRule) send(req *http.Request, base http.RoundTripper) (*http.Response, error) {
+	if r == nil {
+		return base.RoundTrip(req)
+	}
+
+	if err := sleepContext(req.Context(), r.Latency); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	if !r.answers() {
+		return base.RoundTrip(req)
+	}
+
+	// Read the body as a server would, so it is still recorded
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+
+	switch {
+	case r.Drop:
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	case r.Timeout > 0:
+		if err := sleepContext(req.Context(), r.Timeout); err != nil {
+			return nil, err
+		}
+		return nil, faultTimeoutError{}
+	}
+	return r.response(req), nil
+}
+
+// response builds the synthetic response of a Status rule
+func (r *FaultRule) response(req *http.Request) *http.Response {
+	header := make(http.Header, len(r.Headers)+2)
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+	if header.Get("Content-Type") == "" && r.Body != "" {
+		header.Set("Content-Type", http.DetectContentType([]byte(r.Body)))
+	}
+	header.Set("Content-Length", strconv.Itoa(len(r.Body)))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(r.Body))),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// record describes the fault for the logged request. A nil rule records
+// nothing.
+func (r *FaultRule) record() *models.Fault {
+	if r == nil {
+		return nil
+	}
+
+	fault := &models.Fault{Rule: r.Name, Kind: models.FaultLatency, Latency: r.Latency}
+	switch {
+	case r.Status != 0:
+		fault.Kind, fault.Status = models.FaultStatus, r.Status
+	case r.Drop:
+		fault.Kind = models.FaultDrop
+	case r.Timeout > 0:
+		fault.Kind = models.FaultTimeout
+	}
+	return fault
+}
+
+// faultTimeoutError is returned for an injected timeout. Like the errors of
+// real timeouts, it is a net.Error whose Timeout method returns true.
+type faultTimeoutError struct{}
+
+func (faultTimeoutError) Error() string   { return "net/http: timeout awaiting response headers" }
+func (faultTimeoutError) Timeout() bool   { return true }
+func (faultTimeoutError) Temporary() bool { return true }
+
+// sleepContext waits for d, or returns early with the context's error
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeRequestBody closes the body of a request that will not be sent, as a
+// RoundTripper must
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
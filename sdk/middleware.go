@@ -0,0 +1,228 @@
+package slurpy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// Middleware returns server middleware that logs every request a handler
+// receives together with the response it writes. The exchanges are stored
+// with the inbound direction so the CLI can tell them apart from client calls.
+//
+// The middleware owns its own background writer and sink. Close the returned
+// io.Closer on shutdown to write pending logs and close the sink. Use
+// Client.Middleware to share a client's namespace, sink and lifecycle instead.
+func Middleware(config Config) (func(http.Handler) http.Handler, io.Closer, error) {
+	transport, err := NewTransport(nil, config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create middleware: %w", err)
+	}
+	return transport.Middleware, transport, nil
+}
+
+// Middleware wraps next so the requests it serves are logged through this
+// client's settings, redaction and sink
+func (c *Client) Middleware(next http.Handler) http.Handler {
+	return c.transport.Middleware(next)
+}
+
+// Middleware wraps next so the requests it serves are logged through this
+// transport's settings, redaction and sink
+func (t *Transport) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := t.state.Load()
+		opts := optionsFromContext(r.Context())
+		if !state.enabled || opts.skip {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		namespace := state.namespace
+		if opts.namespace != "" {
+			namespace = opts.namespace
+		}
+
+		ex := &exchange{
+			sink:     state.sink,
+			redactor: t.redactor,
+			writer:   t.writer,
+			start:    time.Now(),
+			logged: &models.LoggedRequest{
+				ID:        generateID(),
+				Method:    r.Method,
+				URL:       requestURL(r),
+				Headers:   models.HeadersFromHTTP(r.Header),
+				Namespace: namespace,
+				Direction: models.DirectionInbound,
+				Tags:      opts.tags,
+			},
+		}
+		ex.logged.Timestamp = ex.start
+
+		// Record the request body as the handler reads it. A handler that
+		// never reads its body leaves an empty capture.
+		if r.Body != nil && r.Body != http.NoBody {
+			ex.reqBody = &captureBuffer{
+				limit: t.bodyLimit(r.Header.Get("Content-Type"), t.maxReqBody, opts),
+			}
+			r = r.WithContext(r.Context())
+			r.Body = &teeBody{body: r.Body, buf: ex.reqBody}
+		}
+
+		rw := &responseRecorder{
+			ResponseWriter: w,
+			start:          ex.start,
+			limit: func(contentType string) int64 {
+				return t.bodyLimit(contentType, t.maxRespBody, opts)
+			},
+		}
+
+		// A panicking handler is still logged before the panic continues up
+		// to net/http
+		defer func() {
+			if p := recover(); p != nil {
+				ex.logged.Error = fmt.Sprintf("handler panicked: %v", p)
+				rw.finish(ex, r, true)
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+		rw.finish(ex, r, false)
+	})
+}
+
+// requestURL reconstructs the absolute URL of a request received by a server
+func requestURL(r *http.Request) string {
+	if r.URL.IsAbs() {
+		return r.URL.String()
+	}
+
+	u := *r.URL
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	u.Host = r.Host
+	return u.String()
+}
+
+// responseRecorder wraps a handler's ResponseWriter to record the status,
+// headers and body it writes. It passes Flush and Hijack through to the
+// underlying writer and supports http.ResponseController via Unwrap.
+type responseRecorder struct {
+	http.ResponseWriter
+	limit    func(contentType string) int64
+	start    time.Time
+	status   int
+	header   http.Header // Snapshot taken when the header was sent
+	body     captureBuffer
+	stream   *streamRecorder // Set for event streams
+	writeErr error
+	hijacked bool
+}
+
+// record snapshots the final status and header the first time either is sent
+func (rw *responseRecorder) record(code int, firstWrite []byte) {
+	if rw.status != 0 {
+		return
+	}
+
+	rw.status = code
+	rw.header = rw.Header().Clone()
+	// Mirror net/http, which sniffs a missing Content-Type from the first write
+	if rw.header.Get("Content-Type") == "" && len(firstWrite) > 0 {
+		rw.header.Set("Content-Type", http.DetectContentType(firstWrite))
+	}
+	rw.body.limit = rw.limit(rw.header.Get("Content-Type"))
+	// Handlers decide when to flush, so only event streams are split up
+	rw.stream = newStreamRecorder(rw.header, false, rw.start, rw.body.limit)
+}
+
+func (rw *responseRecorder) WriteHeader(code int) {
+	// Informational responses such as 103 Early Hints may precede the real one
+	if code >= 200 || code == http.StatusSwitchingProtocols {
+		rw.record(code, nil)
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseRecorder) Write(p []byte) (int, error) {
+	rw.record(http.StatusOK, p)
+
+	n, err := rw.ResponseWriter.Write(p)
+	if n > 0 {
+		rw.body.Write(p[:n])
+		if rw.stream != nil {
+			rw.stream.write(p[:n])
+		}
+	}
+	if err != nil && rw.writeErr == nil {
+		rw.writeErr = err
+	}
+	return n, err
+}
+
+// Flush sends buffered data to the client if the underlying writer supports it
+func (rw *responseRecorder) Flush() {
+	rw.record(http.StatusOK, nil)
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection if the underlying writer supports it.
+// Bytes written to a hijacked connection are not recorded.
+func (rw *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("slurpy: %T does not implement http.Hijacker", rw.ResponseWriter)
+	}
+
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		rw.hijacked = true
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// finish records the response and saves the exchange. An aborted handler
+// that sent nothing leaves the client without a response, so none is logged.
+func (rw *responseRecorder) finish(ex *exchange, r *http.Request, aborted bool) {
+	if aborted && rw.status == 0 {
+		ex.finish()
+		return
+	}
+
+	// net/http answers 200 with an empty body when a handler writes nothing
+	if !rw.hijacked {
+		rw.record(http.StatusOK, nil)
+	}
+
+	var localAddr string
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		localAddr = addr.String()
+	}
+
+	ex.logged.Response = &models.LoggedResponse{
+		StatusCode: rw.status,
+		Headers:    models.HeadersFromHTTP(rw.header),
+		Hijacked:   rw.hijacked,
+		Connection: connectionInfo(r.Proto, r.RemoteAddr, localAddr, r.TLS),
+	}
+	if rw.stream != nil {
+		ex.logged.Messages, ex.logged.MessagesDropped = rw.stream.finish()
+	}
+	ex.finishResponse(&rw.body, rw.writeErr != nil, rw.writeErr)
+}
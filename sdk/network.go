@@ -0,0 +1,189 @@
+package slurpy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// networkPresets are the built-in profiles, modelled on the presets of
+// browser dev tools and WebPageTest
+var networkPresets = map[string]models.NetworkProfile{
+	"2g":        {Download: 35000, Upload: 32000, RTT: 800 * time.Millisecond},     // 280/256 kbit/s
+	"slow-3g":   {Download: 50000, Upload: 50000, RTT: 400 * time.Millisecond},     // 400 kbit/s
+	"3g":        {Download: 200000, Upload: 96000, RTT: 300 * time.Millisecond},    // 1.6 Mbit/s, 768 kbit/s
+	"4g":        {Download: 1125000, Upload: 1125000, RTT: 170 * time.Millisecond}, // 9 Mbit/s
+	"slow-wifi": {Download: 125000, Upload: 62500, RTT: 150 * time.Millisecond},    // 1 Mbit/s, 500 kbit/s
+	"wifi":      {Download: 3750000, Upload: 1875000, RTT: 30 * time.Millisecond},  // 30/15 Mbit/s
+}
+
+// ParseNetworkProfile returns a built-in profile by name, such as "3g" or
+// "slow-wifi", or a custom one written as "down=50000,up=20000,rtt=300ms"
+// with rates in bytes per second. Fields left out of a custom profile are
+// unlimited. An empty spec or "off" returns nil, which disables shaping.
+func ParseNetworkProfile(spec string) (*models.NetworkProfile, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || strings.EqualFold(spec, "off") {
+		return nil, nil
+	}
+
+	name := strings.ToLower(spec)
+	if preset, ok := networkPresets[name]; ok {
+		preset.Name = name
+		return &preset, nil
+	}
+	if !strings.Contains(spec, "=") {
+		names := make([]string, 0, len(networkPresets))
+		for name := range networkPresets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown network profile %q: expected one of %s, or down=,up=,rtt=", spec, strings.Join(names, ", "))
+	}
+
+	profile := &models.NetworkProfile{Name: "custom"}
+	for _, field := range strings.Split(spec, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		var err error
+		switch strings.ToLower(key) {
+		case "down":
+			profile.Download, err = strconv.ParseInt(value, 10, 64)
+		case "up":
+			profile.Upload, err = strconv.ParseInt(value, 10, 64)
+		case "rtt":
+			profile.RTT, err = time.ParseDuration(value)
+		default:
+			return nil, fmt.Errorf("invalid network profile %q: unknown field %q", spec, key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid network profile %q: bad %s value %q", spec, key, value)
+		}
+	}
+	return copyNetwork(profile)
+}
+
+// copyNetwork validates a profile and returns a copy, so the caller changing
+// theirs later cannot affect requests in flight. Unnamed profiles are called
+// "custom".
+func copyNetwork(profile *models.NetworkProfile) (*models.NetworkProfile, error) {
+	if profile == nil {
+		return nil, nil
+	}
+	if profile.Download < 0 || profile.Upload < 0 || profile.RTT < 0 {
+		return nil, fmt.Errorf("invalid network profile %q: rates and RTT must not be negative", profile.Name)
+	}
+
+	copied := *profile
+	if copied.Name == "" {
+		copied.Name = "custom"
+	}
+	return &copied, nil
+}
+
+// shapeNetwork returns base with its traffic shaped to profile, or base
+// itself when profile is nil
+func shapeNetwork(profile *models.NetworkProfile, base http.RoundTripper) http.RoundTripper {
+	if profile == nil {
+		return base
+	}
+	return &networkTransport{profile: profile, base: base}
+}
+
+// networkTransport simulates a slow link in front of a RoundTripper. Every
+// request waits one RTT before it is sent, a request that opened a new
+// connection waits one more per handshake, and bodies flow no faster than
+// the profile's rates.
+type networkTransport struct {
+	profile *models.NetworkProfile
+	base    http.RoundTripper
+}
+
+func (n *networkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if err := sleepContext(ctx, n.profile.RTT); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+
+	// The base transport may dial a new connection, which costs a round
+	// trip for TCP and another for TLS
+	var dialed, tls atomic.Bool
+	out := req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn:           func(info httptrace.GotConnInfo) { dialed.Store(!info.Reused) },
+		TLSHandshakeStart: func() { tls.Store(true) },
+	}))
+	if req.Body != nil && req.Body != http.NoBody && n.profile.Upload > 0 {
+		out.Body = &throttledBody{body: req.Body, ctx: ctx, rate: n.profile.Upload}
+	}
+
+	resp, err := n.base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	handshakes := 0
+	for _, done := range []bool{dialed.Load(), tls.Load()} {
+		if done {
+			handshakes++
+		}
+	}
+	if err := sleepContext(ctx, time.Duration(handshakes)*n.profile.RTT); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	// Upgraded connections keep their read-write body
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.Body != nil && resp.Body != http.NoBody && n.profile.Download > 0 {
+		resp.Body = &throttledBody{body: resp.Body, ctx: ctx, rate: n.profile.Download}
+	}
+	return resp, nil
+}
+
+// throttledBody lets a body be read no faster than rate bytes per second,
+// measured from the first read
+type throttledBody struct {
+	body  io.ReadCloser
+	ctx   context.Context
+	rate  int64
+	start time.Time
+	read  int64
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if b.start.IsZero() {
+		b.start = time.Now()
+	}
+
+	// Hand out a tenth of a second's worth at a time, so data trickles in
+	// rather than arriving in bursts
+	chunk := b.rate / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := b.body.Read(p)
+	b.read += int64(n)
+	due := b.start.Add(time.Duration(float64(b.read) / float64(b.rate) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		if ctxErr := sleepContext(b.ctx, wait); ctxErr != nil && err == nil {
+			err = ctxErr
+		}
+	}
+	return n, err
+}
+
+func (b *throttledBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,154 @@
+package slurpy
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// Log records an exchange captured outside net/http, such as a gRPC call,
+// through the client's settings, redaction and sink
+func (c *Client) Log(ctx context.Context, lr *models.LoggedRequest) {
+	c.transport.Log(ctx, lr)
+}
+
+// AppendMessage adds a stream message to an exchange that will be passed to
+// Log, applying the message cap and capture limits as it arrives so
+// long-lived streams stay bounded
+func (c *Client) AppendMessage(ctx context.Context, lr *models.LoggedRequest, msg models.Message) {
+	c.transport.AppendMessage(ctx, lr, msg)
+}
+
+// AppendMessage adds a stream message to an exchange that will be passed to
+// Log. Messages beyond the cap are counted in MessagesDropped and payloads
+// are cut to the capture limit for their direction. Callers appending from
+// several goroutines must serialize the calls.
+func (t *Transport) AppendMessage(ctx context.Context, lr *models.LoggedRequest, msg models.Message) {
+	if len(lr.Messages) >= maxMessages {
+		lr.MessagesDropped++
+		return
+	}
+
+	// The response headers may not have arrived yet, and a stream's content
+	// type is the same both ways
+	opts := optionsFromContext(ctx)
+	max := t.maxRespBody
+	if msg.IsSent() != lr.IsInbound() {
+		max = t.maxReqBody
+	}
+	limitMessage(&msg, t.bodyLimit(lr.Headers.Get("Content-Type"), max, opts))
+	lr.Messages = append(lr.Messages, msg)
+}
+
+// Log records an exchange captured outside net/http. Empty IDs, timestamps,
+// namespaces and directions are filled in, options from ctx apply, and
+// bodies and messages are cut to the capture limits before the exchange is
+// redacted and queued for writing. Nothing is logged while disabled.
+func (t *Transport) Log(ctx context.Context, lr *models.LoggedRequest) {
+	state := t.state.Load()
+	opts := optionsFromContext(ctx)
+	if !state.enabled || opts.skip {
+		return
+	}
+
+	if lr.ID == "" {
+		lr.ID = generateID()
+	}
+	if lr.Timestamp.IsZero() {
+		lr.Timestamp = time.Now()
+	}
+	switch {
+	case opts.namespace != "":
+		lr.Namespace = opts.namespace
+	case lr.Namespace == "":
+		lr.Namespace = state.namespace
+	}
+	if lr.Direction == "" {
+		lr.Direction = models.DirectionOutbound
+	}
+	lr.Tags = append(lr.Tags, opts.tags...)
+
+	t.limitBodies(lr, opts)
+	t.redactor.apply(lr)
+	t.writer.enqueue(writeEntry{req: lr, sink: state.sink})
+}
+
+// limitBodies applies the capture limits to an exchange built by hand.
+// Messages flowing from client to server count against the request limit.
+func (t *Transport) limitBodies(lr *models.LoggedRequest, opts requestOptions) {
+	reqLimit := t.bodyLimit(lr.Headers.Get("Content-Type"), t.maxReqBody, opts)
+	respLimit := int64(-1)
+	if lr.Response != nil {
+		respLimit = t.bodyLimit(lr.Response.Headers.Get("Content-Type"), t.maxRespBody, opts)
+	}
+
+	if lr.BodySize == 0 {
+		lr.BodySize = storedSize(lr.Body, lr.BodyEncoding)
+	}
+	if body, cut := limitStored(lr.Body, lr.BodyEncoding, reqLimit); cut {
+		lr.Body, lr.Truncated = body, true
+	}
+
+	if resp := lr.Response; resp != nil {
+		if resp.Size == 0 {
+			resp.Size = storedSize(resp.Body, resp.BodyEncoding)
+		}
+		if body, cut := limitStored(resp.Body, resp.BodyEncoding, respLimit); cut {
+			resp.Body, resp.Truncated = body, true
+		}
+	}
+
+	for i := range lr.Messages {
+		msg := &lr.Messages[i]
+		limit := respLimit
+		if msg.IsSent() != lr.IsInbound() {
+			limit = reqLimit
+		}
+		limitMessage(msg, limit)
+	}
+}
+
+// limitMessage cuts a stored message down to limit original bytes, keeping
+// its full size
+func limitMessage(msg *models.Message, limit int64) {
+	if msg.Size == 0 {
+		msg.Size = storedSize(msg.Data, msg.Encoding)
+	}
+	if data, cut := limitStored(msg.Data, msg.Encoding, limit); cut {
+		msg.Data, msg.Truncated = data, true
+	}
+}
+
+// storedSize returns the original length of a stored body
+func storedSize(body, encoding string) int64 {
+	data, err := models.DecodeBody(body, encoding)
+	if err != nil {
+		return int64(len(body))
+	}
+	return int64(len(data))
+}
+
+// limitStored cuts a stored body down to limit original bytes and reports
+// whether anything was removed
+func limitStored(body, encoding string, limit int64) (string, bool) {
+	if limit < 0 {
+		return body, false
+	}
+
+	if encoding == "" {
+		if int64(len(body)) <= limit {
+			return body, false
+		}
+		// Drop a rune split by the cut so the text stays valid
+		return strings.ToValidUTF8(body[:limit], ""), true
+	}
+
+	data, err := models.DecodeBody(body, encoding)
+	if err != nil || int64(len(data)) <= limit {
+		return body, false
+	}
+	return base64.StdEncoding.EncodeToString(data[:limit]), true
+}
@@ -0,0 +1,387 @@
+package slurpy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bobby/slurpy/pkg/models"
+	"github.com/bobby/slurpy/pkg/storage"
+)
+
+// Mode decides whether a client sends requests over the network, answers
+// them from earlier recordings, or both
+type Mode string
+
+const (
+	ModeRecord        Mode = "record"         // Send every request and log it; the default
+	ModeReplay        Mode = "replay"         // Answer every request from a recording, never touching the network
+	ModeRecordMissing Mode = "record-missing" // Answer from recordings, sending and logging requests that have none; implies Enabled
+	ModePassthrough   Mode = "passthrough"    // Send every request without logging it
+)
+
+// replays reports whether the mode answers requests from recordings
+func (m Mode) replays() bool {
+	return m == ModeReplay || m == ModeRecordMissing
+}
+
+// ReplayConfig controls where ModeReplay and ModeRecordMissing find their
+// recordings and how a request is matched to one
+type ReplayConfig struct {
+	// Namespace whose stored logs are replayed. Defaults to the client's
+	// namespace.
+	Namespace string
+
+	// Recordings are replayed instead of the stored logs, for example a
+	// cassette kept next to the tests and loaded with ReadJSONL
+	Recordings []*models.LoggedRequest
+
+	Match MatchConfig
+}
+
+// MatchConfig decides when a recording answers a request. By default the
+// method, URL and query string must all match. Query parameters are compared
+// sorted, so their order does not matter.
+type MatchConfig struct {
+	IgnoreMethod      bool
+	IgnoreQuery       bool     // Compare the scheme, host and path only
+	IgnoreQueryParams []string // Parameters left out, such as timestamps or nonces
+	Headers           []string // Request headers that must match as well
+	Body              bool     // Match the request body as well; JSON is compared by value
+}
+
+// NoMatchError is returned when a replaying client has no recording for a
+// request. It lists the closest recordings and how each one differs.
+type NoMatchError struct {
+	Method     string
+	URL        string // Redacted like the recordings
+	Source     string // Where the recordings came from
+	Candidates []Candidate
+}
+
+// Candidate is a recording that came close to matching a request
+type Candidate struct {
+	Recording   *models.LoggedRequest
+	Differences []string
+}
+
+func (e *NoMatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "slurpy: no recording in %s matches %s %s", e.Source, e.Method, e.URL)
+	if len(e.Candidates) == 0 {
+		b.WriteString(" (there are no recordings)")
+		return b.String()
+	}
+
+	b.WriteString("; closest recordings:")
+	for _, c := range e.Candidates {
+		fmt.Fprintf(&b, "\n  %s %s [%s]: %s", c.Recording.Method, c.Recording.URL, c.Recording.ID, strings.Join(c.Differences, "; "))
+	}
+	return b.String()
+}
+
+// maxCandidates is how many near misses a NoMatchError lists
+const maxCandidates = 3
+
+// cassette holds the recordings a Transport replays. Each recording answers
+// one request, in the order they were recorded; once every match has been
+// used, the latest one keeps answering.
+type cassette struct {
+	mu         sync.Mutex
+	match      MatchConfig
+	redactor   *redactor
+	source     string
+	recordings []*recording
+}
+
+type recording struct {
+	req  *models.LoggedRequest
+	used bool
+}
+
+// newCassette loads the recordings for a resolved config
+func newCassette(config Config, redactor *redactor) (*cassette, error) {
+	c := &cassette{match: config.Replay.Match, redactor: redactor, source: "the configured recordings"}
+
+	recordings := config.Replay.Recordings
+	if recordings == nil {
+		namespace := config.Replay.Namespace
+		if namespace == "" {
+			namespace = config.Namespace
+		}
+		store, err := storage.NewWithDir(config.StoreDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		if recordings, err = store.LoadRequests(namespace); err != nil {
+			return nil, fmt.Errorf("failed to load recordings: %w", err)
+		}
+		c.source = fmt.Sprintf("namespace %q", namespace)
+	}
+
+	for _, lr := range recordings {
+		if replayable(lr) {
+			c.recordings = append(c.recordings, &recording{req: lr})
+		}
+	}
+	sort.SliceStable(c.recordings, func(i, j int) bool {
+		return c.recordings[i].req.Timestamp.Before(c.recordings[j].req.Timestamp)
+	})
+	return c, nil
+}
+
+// replayable reports whether an exchange can answer a request: it was sent
+// by an HTTP client and got a response or failed outright. Upgraded
+// connections cannot be replayed.
+func replayable(lr *models.LoggedRequest) bool {
+	if lr.IsInbound() || lr.GRPC != nil {
+		return false
+	}
+	if lr.Response == nil {
+		return lr.Error != ""
+	}
+	return lr.Response.StatusCode != http.StatusSwitchingProtocols
+}
+
+// add makes an exchange recorded in ModeRecordMissing available to later
+// requests
+func (c *cassette) add(lr *models.LoggedRequest) {
+	if !replayable(lr) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recordings = append(c.recordings, &recording{req: lr, used: true})
+}
+
+// lookup finds the recording that answers req. body is the request body,
+// read up front when bodies are matched.
+func (c *cassette) lookup(req *http.Request, body []byte) (*models.LoggedRequest, error) {
+	// Recordings were redacted before they were stored, so the request is
+	// redacted the same way before comparing
+	live := &models.LoggedRequest{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: models.HeadersFromHTTP(req.Header),
+	}
+	if body != nil {
+		live.Body, live.BodyEncoding = models.EncodeBody(body, req.Header.Get("Content-Type"))
+	}
+	c.redactor.apply(live)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	type scored struct {
+		Candidate
+		distance int
+	}
+	var latest *recording
+	var near []scored
+	for _, r := range c.recordings {
+		diffs, distance := c.compare(live, r.req)
+		if len(diffs) > 0 {
+			near = append(near, scored{Candidate{Recording: r.req, Differences: diffs}, distance})
+			continue
+		}
+		if !r.used {
+			r.used = true
+			return r.req, nil
+		}
+		latest = r
+	}
+	if latest != nil {
+		return latest.req, nil
+	}
+
+	// Repeated recordings of the same request are listed once
+	sort.SliceStable(near, func(i, j int) bool { return near[i].distance < near[j].distance })
+	err := &NoMatchError{Method: live.Method, URL: live.URL, Source: c.source}
+	seen := make(map[string]bool)
+	for _, s := range near {
+		key := s.Recording.Method + " " + s.Recording.URL + " " + strings.Join(s.Differences, "; ")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if err.Candidates = append(err.Candidates, s.Candidate); len(err.Candidates) == maxCandidates {
+			break
+		}
+	}
+	return nil, err
+}
+
+// compare lists how a recording differs from a request, with a distance that
+// ranks near misses: a different host counts for more than a different query
+func (c *cassette) compare(live, rec *models.LoggedRequest) ([]string, int) {
+	var diffs []string
+	distance := 0
+	differ := func(weight int, format string, args ...interface{}) {
+		diffs = append(diffs, fmt.Sprintf(format, args...))
+		distance += weight
+	}
+
+	if !c.match.IgnoreMethod && !strings.EqualFold(live.Method, rec.Method) {
+		differ(2, "method is %s, recorded %s", live.Method, rec.Method)
+	}
+
+	liveURL, _ := url.Parse(live.URL)
+	recURL, err := url.Parse(rec.URL)
+	if liveURL == nil || err != nil {
+		differ(8, "url cannot be parsed")
+		return diffs, distance
+	}
+	if !strings.EqualFold(liveURL.Scheme, recURL.Scheme) || !strings.EqualFold(liveURL.Host, recURL.Host) {
+		differ(4, "host is %s://%s, recorded %s://%s", liveURL.Scheme, liveURL.Host, recURL.Scheme, recURL.Host)
+	}
+	if liveURL.Path != recURL.Path {
+		differ(3, "path is %s, recorded %s", liveURL.Path, recURL.Path)
+	}
+	if !c.match.IgnoreQuery {
+		if q, recQ := c.normalizeQuery(liveURL.RawQuery), c.normalizeQuery(recURL.RawQuery); q != recQ {
+			differ(1, "query is %q, recorded %q", q, recQ)
+		}
+	}
+
+	for _, name := range c.match.Headers {
+		if v, recV := live.Headers.Get(name), rec.Headers.Get(name); v != recV {
+			differ(1, "header %s is %q, recorded %q", name, v, recV)
+		}
+	}
+
+	if c.match.Body && !bodiesMatch(live, rec) {
+		differ(1, "body differs (%d bytes, recorded %d)", len(live.Body), len(rec.Body))
+	}
+	return diffs, distance
+}
+
+// normalizeQuery sorts a query string by key and value and drops ignored
+// parameters, so equivalent queries compare equal
+func (c *cassette) normalizeQuery(raw string) string {
+	values, _ := url.ParseQuery(raw)
+	for _, name := range c.match.IgnoreQueryParams {
+		values.Del(name)
+	}
+	for _, v := range values {
+		sort.Strings(v)
+	}
+	// Decoded again so the query reads naturally in a NoMatchError
+	normalized := values.Encode()
+	if decoded, err := url.QueryUnescape(normalized); err == nil {
+		return decoded
+	}
+	return normalized
+}
+
+// bodiesMatch compares request bodies. JSON is compared by value, and a
+// recording whose body was truncated only needs to match as a prefix.
+func bodiesMatch(live, rec *models.LoggedRequest) bool {
+	body, err := live.BodyBytes()
+	if err != nil {
+		return false
+	}
+	recBody, err := rec.BodyBytes()
+	if err != nil {
+		return false
+	}
+
+	if rec.Truncated {
+		return bytes.HasPrefix(body, recBody)
+	}
+	if bytes.Equal(body, recBody) {
+		return true
+	}
+
+	var v, recV interface{}
+	if json.Unmarshal(body, &v) != nil || json.Unmarshal(recBody, &recV) != nil {
+		return false
+	}
+	return reflect.DeepEqual(v, recV)
+}
+
+// replay answers req from the cassette, over the simulated network and with
+// fault applied on top. In ModeRecordMissing a request with no recording is
+// returned instead, with its body restored, to be sent on.
+func (t *Transport) replay(req *http.Request, fault *FaultRule, network *models.NetworkProfile) (*http.Response, *http.Request, error) {
+	var body []byte
+	if t.cassette.match.Body && req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req = req.WithContext(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	rec, err := t.cassette.lookup(req, body)
+	if err != nil && t.mode == ModeRecordMissing {
+		return nil, req, nil
+	}
+
+	if err != nil {
+		closeRequestBody(req)
+		return nil, nil, err
+	}
+
+	resp, err := fault.send(req, shapeNetwork(network, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		// The request is answered here, so its body is never sent
+		closeRequestBody(req)
+		if rec.Response == nil {
+			return nil, fmt.Errorf("slurpy: replayed error from %s: %s", rec.ID, rec.Error)
+		}
+		return replayResponse(req, rec)
+	})))
+	return resp, nil, err
+}
+
+// replayResponse rebuilds the response a recording got
+func replayResponse(req *http.Request, rec *models.LoggedRequest) (*http.Response, error) {
+	recorded := rec.Response
+	body, err := recorded.BodyBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode recorded body of %s: %w", rec.ID, err)
+	}
+
+	header := make(http.Header, len(recorded.Headers))
+	for k, v := range recorded.Headers {
+		header[k] = append([]string(nil), v...)
+	}
+	// Bodies are stored decoded, and may have been truncated
+	if recorded.ContentEncoding != "" {
+		header.Del("Content-Encoding")
+	}
+	if header.Get("Content-Length") != "" {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	var trailer http.Header
+	if len(recorded.Trailers) > 0 {
+		trailer = make(http.Header, len(recorded.Trailers))
+		for k, v := range recorded.Trailers {
+			trailer[k] = append([]string(nil), v...)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Trailer:       trailer,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package slurpy
+
+import (
+	"github.com/bobby/slurpy/pkg/settings"
+)
+
+// resolve layers the config file and environment underneath c. Fields set in
+// code win, except redaction lists, which are combined across every layer.
+func (c Config) resolve() (Config, error) {
+	p, err := settings.Load(c.Namespace)
+	if err != nil {
+		return c, err
+	}
+
+	if !c.Enabled && p.Enabled != nil {
+		c.Enabled = *p.Enabled
+	}
+	if c.Namespace == "" {
+		c.Namespace = p.Namespace
+	}
+	if c.Namespace == "" {
+		c.Namespace = "default"
+	}
+	if c.StoreDir == "" {
+		c.StoreDir = p.StoreDir
+	}
+	if c.Mode == "" {
+		c.Mode = Mode(p.Mode)
+	}
+	if c.Network == nil {
+		if c.Network, err = ParseNetworkProfile(p.Network); err != nil {
+			return c, err
+		}
+	}
+	if c.MaxRequestBodySize == 0 && p.MaxRequestBody != nil {
+		c.MaxRequestBodySize = *p.MaxRequestBody
+	}
+	if c.MaxResponseBodySize == 0 && p.MaxResponseBody != nil {
+		c.MaxResponseBodySize = *p.MaxResponseBody
+	}
+
+	if !c.Redact.DisableDefaults && p.Redact.DisableDefaults != nil {
+		c.Redact.DisableDefaults = *p.Redact.DisableDefaults
+	}
+	c.Redact.Headers = append(p.Redact.Headers, c.Redact.Headers...)
+	c.Redact.QueryParams = append(p.Redact.QueryParams, c.Redact.QueryParams...)
+	c.Redact.JSONPaths = append(p.Redact.JSONPaths, c.Redact.JSONPaths...)
+	c.Redact.Patterns = append(p.Redact.Patterns, c.Redact.Patterns...)
+	return c, nil
+}
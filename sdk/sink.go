@@ -0,0 +1,248 @@
+package slurpy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/models"
+	"github.com/bobby/slurpy/pkg/storage"
+)
+
+// Sink receives finished exchanges. The background writer calls Write from a
+// single goroutine, but a Sink shared between clients must still be safe for
+// concurrent use.
+type Sink interface {
+	Write(ctx context.Context, req *models.LoggedRequest) error
+	Close() error
+}
+
+// FileSink stores each exchange as a JSON file in the slurpy logs directory,
+// which is what the CLI reads
+type FileSink struct {
+	storage *storage.Storage
+}
+
+// NewFileSink creates a FileSink in the default storage location
+func NewFileSink() (*FileSink, error) {
+	return NewFileSinkAt("")
+}
+
+// NewFileSinkAt creates a FileSink storing logs under dir. An empty dir uses
+// the default storage location.
+func NewFileSinkAt(dir string) (*FileSink, error) {
+	store, err := storage.NewWithDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	return &FileSink{storage: store}, nil
+}
+
+// Write saves req as its own JSON file
+func (s *FileSink) Write(_ context.Context, req *models.LoggedRequest) error {
+	return s.storage.SaveRequest(req)
+}
+
+// Close is a no-op; every Write is already on disk
+func (s *FileSink) Close() error {
+	return nil
+}
+
+// JSONLSink appends each exchange as one line of JSON to a single file
+type JSONLSink struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// NewJSONLSink opens path for appending, creating it if needed
+func NewJSONLSink(path string) (*JSONLSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return &JSONLSink{file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// Write appends req as a single line
+func (s *JSONLSink) Write(_ context.Context, req *models.LoggedRequest) error {
+	data, err := req.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// Close closes the underlying file
+func (s *JSONLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.w.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// ReadJSONL loads the exchanges a JSONLSink wrote to path, e.g. to replay
+// them with ReplayConfig.Recordings
+func ReadJSONL(path string) ([]*models.LoggedRequest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var requests []*models.LoggedRequest
+	dec := json.NewDecoder(file)
+	for {
+		var req models.LoggedRequest
+		if err := dec.Decode(&req); err == io.EOF {
+			return requests, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		requests = append(requests, &req)
+	}
+}
+
+// PrettySink prints a one-line summary of each exchange, e.g. to os.Stderr
+type PrettySink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewPrettySink creates a PrettySink writing to w
+func NewPrettySink(w io.Writer) *PrettySink {
+	return &PrettySink{w: w}
+}
+
+// Write prints req as "METHOD URL -> STATUS (duration) [namespace]"
+func (s *PrettySink) Write(_ context.Context, req *models.LoggedRequest) error {
+	status := "ERROR " + req.Error
+	switch {
+	case req.GRPC != nil && req.GRPC.Code != "":
+		status = req.GRPC.Code
+	case req.Response != nil:
+		status = fmt.Sprintf("%d", req.Response.StatusCode)
+	}
+	fault := ""
+	if req.Fault != nil {
+		fault = " fault: " + req.Fault.String()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "%s %s %s -> %s (%v) [%s]%s\n",
+		req.Timestamp.Format("15:04:05"), req.Method, req.URL, status,
+		req.Duration.Truncate(time.Millisecond), req.Namespace, fault)
+	return err
+}
+
+// Close is a no-op; the writer belongs to the caller
+func (s *PrettySink) Close() error {
+	return nil
+}
+
+// MemorySink keeps the most recent exchanges in a fixed-size ring buffer,
+// which is handy in tests
+type MemorySink struct {
+	mu    sync.Mutex
+	ring  []*models.LoggedRequest
+	next  int
+	count int
+}
+
+// NewMemorySink creates a MemorySink holding up to capacity exchanges
+func NewMemorySink(capacity int) *MemorySink {
+	if capacity <= 0 {
+		capacity = DefaultQueueSize
+	}
+	return &MemorySink{ring: make([]*models.LoggedRequest, capacity)}
+}
+
+// Write stores req, evicting the oldest exchange when full
+func (s *MemorySink) Write(_ context.Context, req *models.LoggedRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ring[s.next] = req
+	s.next = (s.next + 1) % len(s.ring)
+	if s.count < len(s.ring) {
+		s.count++
+	}
+	return nil
+}
+
+// Requests returns the stored exchanges, oldest first
+func (s *MemorySink) Requests() []*models.LoggedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make([]*models.LoggedRequest, 0, s.count)
+	start := (s.next - s.count + len(s.ring)) % len(s.ring)
+	for i := 0; i < s.count; i++ {
+		requests = append(requests, s.ring[(start+i)%len(s.ring)])
+	}
+	return requests
+}
+
+// Reset discards every stored exchange
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.ring {
+		s.ring[i] = nil
+	}
+	s.next, s.count = 0, 0
+}
+
+// Close is a no-op; stored exchanges stay readable
+func (s *MemorySink) Close() error {
+	return nil
+}
+
+// multiSink fans each exchange out to several sinks
+type multiSink []Sink
+
+// NewMultiSink creates a Sink that writes every exchange to all of sinks.
+// A failing sink does not stop the others; their errors are joined.
+func NewMultiSink(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+func (m multiSink) Write(ctx context.Context, req *models.LoggedRequest) error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Write(ctx, req); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiSink) Close() error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -1,120 +1,137 @@
 package slurpy
 
 import (
-	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"fmt"
 	"io"
 	"net/http"
-	"time"
 
 	"github.com/bobby/slurpy/pkg/models"
-	"github.com/bobby/slurpy/pkg/storage"
 )
 
-// Client wraps http.Client with logging capabilities
+// Client wraps http.Client with logging capabilities.
+// Logging happens in the client's Transport, so the embedded *http.Client
+// can be handed to code that expects a plain *http.Client.
+//
+// A Client is safe for concurrent use by multiple goroutines. SetNamespace
+// and SetEnabled may be called while requests are in flight; they apply to
+// requests started after they return.
 type Client struct {
 	*http.Client
-	namespace string
-	enabled   bool
-	storage   *storage.Storage
+	transport *Transport
 }
 
-// Config holds configuration for the Slurpy client
+// Config holds configuration for the Slurpy client.
+//
+// Settings from ~/.config/slurpy/config.yaml and SLURPY_* environment
+// variables are layered underneath, so capture can be switched on in a
+// deployed binary. Values set here win; zero values, including Enabled set to
+// false, leave the file and environment in charge.
 type Config struct {
 	Namespace string // Unique identifier for this project/program
 	Enabled   bool   // Whether to enable request logging
-}
 
-// New creates a new Slurpy client
-func New(config Config) (*Client, error) {
-	if config.Namespace == "" {
-		config.Namespace = "default"
-	}
+	// StoreDir is where the default FileSink keeps its logs folder.
+	// Defaults to ~/.config/slurpy.
+	StoreDir string
 
-	var store *storage.Storage
-	var err error
+	// Mode switches between recording traffic and replaying it, e.g. to run
+	// integration tests offline. The replay modes answer requests from
+	// recordings even when logging is disabled. Defaults to ModeRecord.
+	Mode   Mode
+	Replay ReplayConfig
 
-	if config.Enabled {
-		store, err = storage.New()
-		if err != nil {
-			return nil, fmt.Errorf("failed to initialize storage: %w", err)
-		}
-	}
+	// Faults make matching requests slow or fail on purpose, to test how
+	// the program handles a misbehaving dependency. They apply whether or
+	// not logging is enabled and can be changed later with SetFaults.
+	Faults []FaultRule
 
-	return &Client{
-		Client:    &http.Client{},
-		namespace: config.Namespace,
-		enabled:   config.Enabled,
-		storage:   store,
-	}, nil
-}
+	// Network simulates a slow connection: bodies are throttled and latency
+	// is added per round trip. Use ParseNetworkProfile for a built-in
+	// profile such as "3g", or fill in a custom one. Nil sends at full
+	// speed. It applies whether or not logging is enabled.
+	Network *models.NetworkProfile
 
-// Do executes an HTTP request with logging
-func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	if !c.enabled {
-		return c.Client.Do(req)
-	}
+	// Breakpoints pause matching requests or responses until they are
+	// continued, edited or aborted in a running slurpy CLI. Requests never
+	// wait when no CLI is running. They can be changed later with
+	// SetBreakpoints.
+	Breakpoints []Breakpoint
 
-	startTime := time.Now()
-	reqID := generateID()
+	// Body capture limits in bytes. Zero uses DefaultMaxBodySize and a
+	// negative value stores bodies in full. Bodies over the limit are
+	// truncated and flagged, but the caller always receives every byte.
+	MaxRequestBodySize  int64
+	MaxResponseBodySize int64
 
-	// Create logged request
-	loggedReq := &models.LoggedRequest{
-		ID:        reqID,
-		Timestamp: startTime,
-		Method:    req.Method,
-		URL:       req.URL.String(),
-		Headers:   models.HeadersFromHTTP(req.Header),
-		Namespace: c.namespace,
-	}
+	// BodyRules override the limits per content type. The first matching
+	// rule wins, e.g. {ContentType: "video/*", Skip: true}.
+	BodyRules []BodyRule
 
-	// Capture request body if present
-	if req.Body != nil {
-		bodyBytes, err := io.ReadAll(req.Body)
-		if err == nil {
-			loggedReq.Body = string(bodyBytes)
-			// Restore body for the actual request
-			req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-		}
-	}
+	// Redact masks secrets before anything is persisted. Auth headers,
+	// cookies and common token parameters are covered by default.
+	Redact RedactConfig
 
-	// Execute the request
-	resp, err := c.Client.Do(req)
-	duration := time.Since(startTime)
-	loggedReq.Duration = duration
+	// Exchanges are written by a background goroutine. QueueSize bounds how
+	// many can wait (0 uses DefaultQueueSize) and OverflowPolicy decides what
+	// happens when the queue is full.
+	QueueSize      int
+	OverflowPolicy OverflowPolicy
 
-	if err != nil {
-		loggedReq.Error = err.Error()
-	} else {
-		// Capture response
-		loggedResp := &models.LoggedResponse{
-			StatusCode: resp.StatusCode,
-			Headers:    models.HeadersFromHTTP(resp.Header),
-		}
+	// Sink receives every finished exchange. Defaults to a FileSink in
+	// ~/.config/slurpy, which is what the CLI reads. Closing the client
+	// closes the sink.
+	Sink Sink
 
-		// Capture response body
-		if resp.Body != nil {
-			bodyBytes, readErr := io.ReadAll(resp.Body)
-			if readErr == nil {
-				loggedResp.Body = string(bodyBytes)
-				loggedResp.Size = int64(len(bodyBytes))
-				// Restore body for the caller
-				resp.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-			}
-		}
+	// ErrorHandler receives logging failures, which never fail the request
+	// itself. Defaults to printing them with the standard logger.
+	ErrorHandler func(error)
+}
 
-		loggedReq.Response = loggedResp
+// New creates a new Slurpy client
+func New(config Config) (*Client, error) {
+	return Wrap(&http.Client{}, config)
+}
+
+// Wrap instruments an existing *http.Client in place. Only the Transport field
+// is replaced, with a logging Transport on top of the original one, so the
+// client's Timeout, Jar, CheckRedirect and TLS settings keep working as before.
+// A client that is already wrapped gets its logging transport replaced rather
+// than stacked, so requests are never logged twice; the replaced transport is
+// closed after writing its pending logs. A client without a Transport uses
+// http.DefaultTransport, which is unwrapped the same way but left open, since
+// whoever wrapped it owns it.
+func Wrap(c *http.Client, config Config) (*Client, error) {
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	existing, _ := base.(*Transport)
+	if existing != nil {
+		base = existing.Base()
 	}
 
-	// Save the logged request
-	if saveErr := c.storage.SaveRequest(loggedReq); saveErr != nil {
-		// Don't fail the original request if logging fails
-		fmt.Printf("Warning: failed to save request log: %v\n", saveErr)
+	transport, err := NewTransport(base, config)
+	if err != nil {
+		return nil, err
 	}
 
-	return resp, err
+	owned := c.Transport != nil && c.Transport != http.DefaultTransport
+	c.Transport = transport
+	if existing != nil && owned {
+		existing.Close()
+	}
+	return &Client{
+		Client:    c,
+		transport: transport,
+	}, nil
+}
+
+// Do executes an HTTP request with logging. A request that hits a
+// Breakpoint blocks until it is continued or aborted in a running CLI.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.Client.Do(req)
 }
 
 // Get executes a GET request with logging
@@ -159,32 +176,58 @@ func (c *Client) Delete(url string) (*http.Response, error) {
 	return c.Do(req)
 }
 
+// Flush waits until every request logged so far has been written, or until
+// ctx is done
+func (c *Client) Flush(ctx context.Context) error {
+	return c.transport.Flush(ctx)
+}
+
+// Close writes any pending logs and stops the background writer. Call it
+// before the program exits so no requests are lost.
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+// Dropped returns the number of requests whose logs were discarded because
+// the write queue was full or the client was closed
+func (c *Client) Dropped() uint64 {
+	return c.transport.Dropped()
+}
+
 // SetNamespace updates the namespace for future requests
 func (c *Client) SetNamespace(namespace string) {
-	c.namespace = namespace
+	c.transport.SetNamespace(namespace)
 }
 
 // GetNamespace returns the current namespace
 func (c *Client) GetNamespace() string {
-	return c.namespace
+	return c.transport.GetNamespace()
 }
 
 // SetEnabled enables or disables request logging
 func (c *Client) SetEnabled(enabled bool) error {
-	if enabled && c.storage == nil {
-		store, err := storage.New()
-		if err != nil {
-			return fmt.Errorf("failed to initialize storage: %w", err)
-		}
-		c.storage = store
-	}
-	c.enabled = enabled
-	return nil
+	return c.transport.SetEnabled(enabled)
 }
 
 // IsEnabled returns whether request logging is enabled
 func (c *Client) IsEnabled() bool {
-	return c.enabled
+	return c.transport.IsEnabled()
+}
+
+// SetFaults replaces the fault rules for future requests
+func (c *Client) SetFaults(rules []FaultRule) error {
+	return c.transport.SetFaults(rules)
+}
+
+// SetBreakpoints replaces the breakpoints for future requests
+func (c *Client) SetBreakpoints(breakpoints []Breakpoint) error {
+	return c.transport.SetBreakpoints(breakpoints)
+}
+
+// SetNetwork switches the simulated network for future requests; nil turns
+// shaping off
+func (c *Client) SetNetwork(profile *models.NetworkProfile) error {
+	return c.transport.SetNetwork(profile)
 }
 
 // generateID creates a random hex ID
@@ -194,14 +237,64 @@ func generateID() string {
 	return hex.EncodeToString(bytes)
 }
 
-// WrapDefaultClient replaces the default http client methods with logged versions
-func WrapDefaultClient(config Config) error {
-	client, err := New(config)
+// installs reports whether the config needs a Transport at all: one that
+// neither logs, replays, injects faults, shapes the network nor pauses at
+// breakpoints would only pass requests through
+func (c Config) installs() bool {
+	return c.Enabled || c.Mode.replays() || len(c.Faults) > 0 || c.Network != nil || len(c.Breakpoints) > 0
+}
+
+// WrapDefaultClient installs a logging Transport on http.DefaultClient so that
+// package-level calls such as http.Get and http.Post are logged. The returned
+// function restores the previous transport and writes any pending logs. When
+// config.Enabled is false nothing is installed and the returned function is
+// a no-op, unless the config file or environment enables capture, or a
+// replay mode, fault rules, a network profile or breakpoints are set.
+func WrapDefaultClient(config Config) (func(), error) {
+	config, err := config.resolve()
+	if err != nil {
+		return nil, err
+	}
+	if !config.installs() {
+		return func() {}, nil
+	}
+
+	previous := http.DefaultClient.Transport
+	transport, err := newTransport(previous, config)
+	if err != nil {
+		return nil, err
+	}
+
+	http.DefaultClient.Transport = transport
+	return func() {
+		http.DefaultClient.Transport = previous
+		transport.Close()
+	}, nil
+}
+
+// WrapDefaultTransport replaces http.DefaultTransport with a logging Transport,
+// which also covers clients that leave their Transport field unset. The
+// returned function restores the previous transport and writes any pending
+// logs. Use either this or WrapDefaultClient, not both, or requests made
+// through http.DefaultClient will be logged twice.
+func WrapDefaultTransport(config Config) (func(), error) {
+	config, err := config.resolve()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !config.installs() {
+		return func() {}, nil
 	}
 
-	// Override package-level functions
-	http.DefaultClient = client.Client
-	return nil
+	previous := http.DefaultTransport
+	transport, err := newTransport(previous, config)
+	if err != nil {
+		return nil, err
+	}
+
+	http.DefaultTransport = transport
+	return func() {
+		http.DefaultTransport = previous
+		transport.Close()
+	}, nil
 }
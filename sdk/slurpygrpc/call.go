@@ -0,0 +1,195 @@
+// Package slurpygrpc logs gRPC calls through a slurpy client, so gRPC and
+// HTTP traffic show up side by side in the CLI.
+//
+// Payloads are rendered as JSON with protojson where possible. Unary calls
+// store the request and reply as bodies; streams store each message with its
+// arrival time. The call's status is kept in LoggedRequest.GRPC.
+package slurpygrpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/models"
+	slurpy "github.com/bobby/slurpy/sdk"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Method is the LoggedRequest method recorded for gRPC calls
+const Method = "GRPC"
+
+// call accumulates one gRPC call until it completes. Stream messages may be
+// sent and received on different goroutines.
+type call struct {
+	client *slurpy.Client
+	ctx    context.Context
+	start  time.Time
+	mu     sync.Mutex
+	logged *models.LoggedRequest
+	header metadata.MD
+	once   sync.Once
+}
+
+// newCall starts recording a call to fullMethod ("/package.Service/Method")
+func newCall(ctx context.Context, client *slurpy.Client, fullMethod, authority, direction, kind string, md metadata.MD) *call {
+	service, method := splitMethod(fullMethod)
+	start := time.Now()
+
+	return &call{
+		client: client,
+		ctx:    ctx,
+		start:  start,
+		logged: &models.LoggedRequest{
+			Timestamp: start,
+			Method:    Method,
+			URL:       "grpc://" + authority + fullMethod,
+			Headers:   headersFromMD(md),
+			Direction: direction,
+			GRPC: &models.GRPCInfo{
+				Service: service,
+				Method:  method,
+				Kind:    kind,
+			},
+		},
+	}
+}
+
+// kind names the shape of a call from its streaming flags
+func kind(clientStreams, serverStreams bool) string {
+	switch {
+	case clientStreams && serverStreams:
+		return models.GRPCBidiStream
+	case clientStreams:
+		return models.GRPCClientStream
+	case serverStreams:
+		return models.GRPCServerStream
+	}
+	return models.GRPCUnary
+}
+
+// setRequest stores the request message of a unary call
+func (c *call) setRequest(msg interface{}) {
+	c.logged.Body, c.logged.BodyEncoding, _ = encodeMessage(msg)
+}
+
+// message records a stream message. The client caps and cuts messages as
+// they arrive, so a long-lived stream does not hold every payload until it ends.
+func (c *call) message(direction string, msg interface{}) {
+	data, encoding, typ := encodeMessage(msg)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.client.AppendMessage(c.ctx, c.logged, models.Message{
+		Direction: direction,
+		Offset:    time.Since(c.start),
+		Type:      typ,
+		Data:      data,
+		Encoding:  encoding,
+	})
+}
+
+// setHeader merges response header metadata sent by a server handler
+func (c *call) setHeader(md metadata.MD) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header = metadata.Join(c.header, md)
+}
+
+// finish records the reply and status and logs the call, once
+func (c *call) finish(reply interface{}, err error, header, trailer metadata.MD, p *peer.Peer) {
+	c.once.Do(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		lr := c.logged
+		lr.Duration = time.Since(c.start)
+
+		// gRPC status travels in trailers, so there is no HTTP status to
+		// record; the CLI shows the gRPC code instead
+		resp := &models.LoggedResponse{
+			Headers:  headersFromMD(metadata.Join(c.header, header)),
+			Trailers: headersFromMD(trailer),
+		}
+		if reply != nil && err == nil {
+			resp.Body, resp.BodyEncoding, _ = encodeMessage(reply)
+		}
+		if p != nil && p.Addr != nil {
+			resp.Connection = &models.ConnectionInfo{Protocol: "HTTP/2.0", RemoteAddr: p.Addr.String()}
+		}
+		lr.Response = resp
+
+		st := status.Convert(err)
+		lr.GRPC.Code = st.Code().String()
+		lr.GRPC.StatusMessage = st.Message()
+		if err != nil {
+			lr.Error = err.Error()
+		}
+
+		c.client.Log(c.ctx, lr)
+	})
+}
+
+// splitMethod splits "/package.Service/Method" into its service and method
+func splitMethod(fullMethod string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "", fullMethod
+	}
+	return service, method
+}
+
+// encodeMessage renders a message for storage, returning its stored data,
+// encoding and type name. Protobuf messages become JSON; anything else falls
+// back to encoding/json, or to raw bytes for codecs that pass []byte.
+func encodeMessage(msg interface{}) (data, encoding, typ string) {
+	switch m := msg.(type) {
+	case proto.Message:
+		typ = string(proto.MessageName(m))
+		if b, err := protojson.Marshal(m); err == nil {
+			return string(b), "", typ
+		}
+		b, err := proto.Marshal(m)
+		if err != nil {
+			return fmt.Sprintf("[unencodable message: %v]", err), "", typ
+		}
+		data, encoding = models.EncodeBody(b, "application/x-protobuf")
+		return data, encoding, typ
+	case []byte:
+		data, encoding = models.EncodeBody(m, "")
+		return data, encoding, ""
+	}
+
+	typ = fmt.Sprintf("%T", msg)
+	if b, err := json.Marshal(msg); err == nil {
+		return string(b), "", typ
+	}
+	return fmt.Sprintf("%+v", msg), "", typ
+}
+
+// headersFromMD converts gRPC metadata to stored headers. Binary "-bin"
+// values are base64-encoded as they are on the wire.
+func headersFromMD(md metadata.MD) models.Headers {
+	headers := make(models.Headers, len(md))
+	for k, values := range md {
+		key := http.CanonicalHeaderKey(k)
+		for _, v := range values {
+			if strings.HasSuffix(k, "-bin") {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			headers[key] = append(headers[key], v)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,109 @@
+package slurpygrpc
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/bobby/slurpy/pkg/models"
+	slurpy "github.com/bobby/slurpy/sdk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// UnaryClientInterceptor logs unary calls made through a grpc.ClientConn.
+// Install it with grpc.WithUnaryInterceptor.
+func UnaryClientInterceptor(client *slurpy.Client) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !client.IsEnabled() {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		md, _ := metadata.FromOutgoingContext(ctx)
+		c := newCall(ctx, client, method, authority(cc), models.DirectionOutbound, models.GRPCUnary, md)
+		c.setRequest(req)
+
+		var header, trailer metadata.MD
+		var p peer.Peer
+		opts = append(opts, grpc.Header(&header), grpc.Trailer(&trailer), grpc.Peer(&p))
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		c.finish(reply, err, header, trailer, &p)
+		return err
+	}
+}
+
+// StreamClientInterceptor logs streaming calls made through a
+// grpc.ClientConn. Install it with grpc.WithStreamInterceptor. A stream is
+// logged once it ends with an error or io.EOF; streams the caller abandons
+// without reading to the end are not logged.
+func StreamClientInterceptor(client *slurpy.Client) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if !client.IsEnabled() {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+
+		md, _ := metadata.FromOutgoingContext(ctx)
+		c := newCall(ctx, client, method, authority(cc), models.DirectionOutbound, kind(desc.ClientStreams, desc.ServerStreams), md)
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			c.finish(nil, err, nil, nil, nil)
+			return nil, err
+		}
+		return &clientStream{ClientStream: stream, call: c, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+// clientStream records the messages of a client-side stream
+type clientStream struct {
+	grpc.ClientStream
+	call          *call
+	serverStreams bool
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.message(models.MessageSent, m)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.call.message(models.MessageReceived, m)
+		// A call without a server stream has exactly one reply, and callers
+		// do not read on to io.EOF after it
+		if !s.serverStreams {
+			s.end(nil)
+		}
+		return nil
+	}
+
+	if err == io.EOF {
+		s.end(nil)
+	} else {
+		s.end(err)
+	}
+	return err
+}
+
+// end logs the stream once its status is known
+func (s *clientStream) end(err error) {
+	header, _ := s.ClientStream.Header()
+	p, _ := peer.FromContext(s.ClientStream.Context())
+	s.call.finish(nil, err, header, s.ClientStream.Trailer(), p)
+}
+
+// authority returns the host a connection dials, without a resolver scheme
+// such as "dns:///"
+func authority(cc *grpc.ClientConn) string {
+	target := cc.Target()
+	if i := strings.Index(target, ":///"); i >= 0 {
+		return target[i+len(":///"):]
+	}
+	return target
+}
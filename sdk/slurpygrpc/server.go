@@ -0,0 +1,145 @@
+package slurpygrpc
+
+import (
+	"context"
+
+	"github.com/bobby/slurpy/pkg/models"
+	slurpy "github.com/bobby/slurpy/sdk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// UnaryServerInterceptor logs unary calls received by a grpc.Server. Install
+// it with grpc.UnaryInterceptor or grpc.ChainUnaryInterceptor.
+func UnaryServerInterceptor(client *slurpy.Client) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !client.IsEnabled() {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		c := newCall(ctx, client, info.FullMethod, serverAuthority(md), models.DirectionInbound, models.GRPCUnary, md)
+		c.setRequest(req)
+
+		// Headers and trailers set with grpc.SetHeader and friends go
+		// through the transport stream in the context
+		var trailer metadata.MD
+		if stream := grpc.ServerTransportStreamFromContext(ctx); stream != nil {
+			ctx = grpc.NewContextWithServerTransportStream(ctx, &transportStream{
+				ServerTransportStream: stream,
+				call:                  c,
+				trailer:               &trailer,
+			})
+		}
+
+		reply, err := handler(ctx, req)
+		p, _ := peer.FromContext(ctx)
+		c.finish(reply, err, nil, trailer, p)
+		return reply, err
+	}
+}
+
+// StreamServerInterceptor logs streaming calls received by a grpc.Server.
+// Install it with grpc.StreamInterceptor or grpc.ChainStreamInterceptor.
+func StreamServerInterceptor(client *slurpy.Client) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !client.IsEnabled() {
+			return handler(srv, ss)
+		}
+
+		ctx := ss.Context()
+		md, _ := metadata.FromIncomingContext(ctx)
+		c := newCall(ctx, client, info.FullMethod, serverAuthority(md), models.DirectionInbound, kind(info.IsClientStream, info.IsServerStream), md)
+
+		stream := &serverStream{ServerStream: ss, call: c}
+		err := handler(srv, stream)
+		p, _ := peer.FromContext(ctx)
+		c.finish(nil, err, nil, stream.trailer, p)
+		return err
+	}
+}
+
+// serverStream records the messages and metadata of a server-side stream
+type serverStream struct {
+	grpc.ServerStream
+	call    *call
+	trailer metadata.MD
+}
+
+func (s *serverStream) SetHeader(md metadata.MD) error {
+	err := s.ServerStream.SetHeader(md)
+	if err == nil {
+		s.call.setHeader(md)
+	}
+	return err
+}
+
+func (s *serverStream) SendHeader(md metadata.MD) error {
+	err := s.ServerStream.SendHeader(md)
+	if err == nil {
+		s.call.setHeader(md)
+	}
+	return err
+}
+
+func (s *serverStream) SetTrailer(md metadata.MD) {
+	s.ServerStream.SetTrailer(md)
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.call.message(models.MessageSent, m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.call.message(models.MessageReceived, m)
+	}
+	return err
+}
+
+// transportStream records the metadata a unary handler sets
+type transportStream struct {
+	grpc.ServerTransportStream
+	call    *call
+	trailer *metadata.MD
+}
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	err := s.ServerTransportStream.SetHeader(md)
+	if err == nil {
+		s.call.setHeader(md)
+	}
+	return err
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error {
+	err := s.ServerTransportStream.SendHeader(md)
+	if err == nil {
+		s.call.setHeader(md)
+	}
+	return err
+}
+
+func (s *transportStream) SetTrailer(md metadata.MD) error {
+	err := s.ServerTransportStream.SetTrailer(md)
+	if err == nil {
+		*s.trailer = metadata.Join(*s.trailer, md)
+	}
+	return err
+}
+
+// serverAuthority returns the host a client called, from the :authority
+// pseudo-header
+func serverAuthority(md metadata.MD) string {
+	if values := md.Get(":authority"); len(values) > 0 {
+		return values[0]
+	}
+	return "localhost"
+}
@@ -0,0 +1,196 @@
+package slurpy
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// Message types recorded for streamed responses
+const (
+	streamChunk   = "chunk"   // One read of a body of unknown length, stored as its size only
+	streamComment = "comment" // An SSE comment line, often a keep-alive
+	sseDefault    = "message" // An SSE event without an event field
+)
+
+// streamRecorder splits a streamed response body into timed messages: one per
+// server-sent event for text/event-stream, or one per read of a body of
+// unknown length. Chunk messages keep only their size, since the body already
+// holds their bytes and the redactor could not mask secrets split across
+// them. Event payloads share the response capture limit, so a stream is never
+// stored more than twice.
+type streamRecorder struct {
+	mu      sync.Mutex
+	start   time.Time
+	sse     bool
+	limit   int64 // Payload bytes left to store; negative means unlimited
+	msgs    []models.Message
+	dropped int64
+
+	// SSE parser state. Lines and data stop growing once they pass what the
+	// limit lets an event store; only their sizes keep counting.
+	line     []byte
+	lineSize int64 // Bytes of the line being read, including any not kept
+	lineCut  bool  // Part of the line was not kept
+	raw      int64 // Bytes of the event being assembled
+	event    string
+	id       string
+	data     []string
+	held     int64 // Bytes kept in data
+	cut      bool  // Data was left out of the event
+	hasData  bool
+}
+
+// maxFieldPrefix is the longest field name of an event stream line with its
+// colon and space, "retry: "
+const maxFieldPrefix = 7
+
+// newStreamRecorder returns a recorder for a response with the given header,
+// or nil if it is neither an event stream nor of unknown length, as chunked
+// HTTP/1.1 and most streamed HTTP/2 responses are. Compressed bodies are only
+// recorded per read, since their events cannot be parsed before decoding.
+func newStreamRecorder(header http.Header, unknownLength bool, start time.Time, limit int64) *streamRecorder {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	encoding := header.Get("Content-Encoding")
+	compressed := encoding != "" && !strings.EqualFold(encoding, "identity")
+
+	eventStream := mediaType == "text/event-stream"
+	if !eventStream && !unknownLength {
+		return nil
+	}
+	return &streamRecorder{start: start, sse: eventStream && !compressed, limit: limit}
+}
+
+// write records bytes as they are read by the caller
+func (r *streamRecorder) write(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.sse {
+		r.mark(models.Message{Direction: models.MessageReceived, Type: streamChunk, Size: int64(len(p))})
+		return
+	}
+
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			r.appendLine(p)
+			r.raw += int64(len(p))
+			return
+		}
+		r.appendLine(p[:i])
+		r.raw += int64(i + 1)
+		p = p[i+1:]
+
+		r.parseLine(bytes.TrimSuffix(r.line, []byte("\r")))
+		r.line, r.lineSize, r.lineCut = r.line[:0], 0, false
+	}
+}
+
+// appendLine adds bytes to the line being read, keeping no more than the
+// event could still store. The field name is always kept.
+func (r *streamRecorder) appendLine(p []byte) {
+	r.lineSize += int64(len(p))
+	if r.limit >= 0 {
+		room := max(max(r.limit-r.held, 0)+maxFieldPrefix-int64(len(r.line)), 0)
+		if int64(len(p)) > room {
+			p, r.lineCut = p[:room], true
+		}
+	}
+	r.line = append(r.line, p...)
+}
+
+// parseLine handles one line of an event stream
+func (r *streamRecorder) parseLine(line []byte) {
+	if len(line) == 0 && !r.lineCut {
+		r.dispatch()
+		return
+	}
+
+	field, value, _ := strings.Cut(string(line), ":")
+	value = strings.TrimPrefix(value, " ")
+	switch field {
+	case "":
+		r.add(models.Message{Direction: models.MessageReceived, Type: streamComment, Size: r.lineSize, Truncated: r.lineCut}, []byte(value))
+	case "event":
+		r.event = value
+	case "id":
+		r.id = value
+	case "data":
+		r.hasData = true
+		if r.limit >= 0 && r.held >= r.limit {
+			r.cut = true
+			return
+		}
+		r.data = append(r.data, value)
+		r.held += int64(len(value)) + 1
+		r.cut = r.cut || r.lineCut
+	}
+}
+
+// dispatch records the event assembled so far
+func (r *streamRecorder) dispatch() {
+	if r.hasData || r.event != "" {
+		event := r.event
+		if event == "" {
+			event = sseDefault
+		}
+		r.add(models.Message{
+			Direction: models.MessageReceived,
+			Type:      event,
+			ID:        r.id,
+			Size:      r.raw,
+			Truncated: r.cut,
+		}, []byte(strings.Join(r.data, "\n")))
+	}
+	r.raw, r.event, r.id, r.data, r.held, r.cut, r.hasData = 0, "", "", nil, 0, false, false
+}
+
+// mark stores a message without a payload
+func (r *streamRecorder) mark(msg models.Message) {
+	if len(r.msgs) >= maxMessages {
+		r.dropped++
+		return
+	}
+	msg.Offset = time.Since(r.start)
+	r.msgs = append(r.msgs, msg)
+}
+
+// add stores a message, keeping its payload while the limit allows
+func (r *streamRecorder) add(msg models.Message, payload []byte) {
+	if len(r.msgs) >= maxMessages {
+		r.dropped++
+		return
+	}
+
+	if r.limit >= 0 {
+		if int64(len(payload)) > r.limit {
+			payload, msg.Truncated = payload[:r.limit], true
+		}
+		r.limit -= int64(len(payload))
+	}
+
+	msg.Data, msg.Encoding = models.EncodeBody(payload, "text/plain")
+	r.mark(msg)
+}
+
+// finish returns the recorded messages, including an event the stream ended
+// in the middle of
+func (r *streamRecorder) finish() ([]models.Message, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sse {
+		if r.lineSize > 0 {
+			r.parseLine(bytes.TrimSuffix(r.line, []byte("\r")))
+			r.line, r.lineSize, r.lineCut = nil, 0, false
+		}
+		r.dispatch()
+	}
+	return r.msgs, r.dropped
+}
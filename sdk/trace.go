@@ -0,0 +1,125 @@
+package slurpy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// exchangeTrace collects phase timestamps and connection details through
+// httptrace. Hooks may fire on the transport's goroutines, so every field is
+// guarded by mu.
+type exchangeTrace struct {
+	mu         sync.Mutex
+	start      time.Time
+	dnsStart   time.Time
+	dnsDone    time.Time
+	connStart  time.Time
+	connDone   time.Time
+	tlsStart   time.Time
+	tlsDone    time.Time
+	wrote      time.Time
+	firstByte  time.Time
+	connReused bool
+	remoteAddr string
+	localAddr  string
+}
+
+// clientTrace returns the httptrace hooks feeding this trace
+func (tt *exchangeTrace) clientTrace() *httptrace.ClientTrace {
+	record := func(at *time.Time) {
+		tt.mu.Lock()
+		defer tt.mu.Unlock()
+		*at = time.Now()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { record(&tt.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { record(&tt.dnsDone) },
+		ConnectStart: func(_, _ string) {
+			tt.mu.Lock()
+			defer tt.mu.Unlock()
+			// Dialers may race several addresses; the first attempt counts
+			if tt.connStart.IsZero() {
+				tt.connStart = time.Now()
+			}
+		},
+		ConnectDone:       func(_, _ string, _ error) { record(&tt.connDone) },
+		TLSHandshakeStart: func() { record(&tt.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { record(&tt.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			tt.mu.Lock()
+			defer tt.mu.Unlock()
+			tt.connReused = info.Reused
+			if info.Conn != nil {
+				tt.remoteAddr = info.Conn.RemoteAddr().String()
+				tt.localAddr = info.Conn.LocalAddr().String()
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { record(&tt.wrote) },
+		GotFirstResponseByte: func() { record(&tt.firstByte) },
+	}
+}
+
+// timing summarizes the trace for an exchange that ended at end
+func (tt *exchangeTrace) timing(end time.Time) *models.Timing {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	between := func(from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return to.Sub(from)
+	}
+
+	return &models.Timing{
+		DNS:             between(tt.dnsStart, tt.dnsDone),
+		Connect:         between(tt.connStart, tt.connDone),
+		TLSHandshake:    between(tt.tlsStart, tt.tlsDone),
+		Wait:            between(tt.wrote, tt.firstByte),
+		TimeToFirstByte: between(tt.start, tt.firstByte),
+		ContentTransfer: between(tt.firstByte, end),
+		ConnReused:      tt.connReused,
+	}
+}
+
+// connection describes the connection resp was received on
+func (tt *exchangeTrace) connection(resp *http.Response) *models.ConnectionInfo {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	return connectionInfo(resp.Proto, tt.remoteAddr, tt.localAddr, resp.TLS)
+}
+
+// connectionInfo describes a connection and, if it used TLS, the peer's
+// certificate chain
+func connectionInfo(proto, remoteAddr, localAddr string, state *tls.ConnectionState) *models.ConnectionInfo {
+	info := &models.ConnectionInfo{
+		Protocol:   proto,
+		RemoteAddr: remoteAddr,
+		LocalAddr:  localAddr,
+	}
+
+	if state != nil {
+		info.TLSVersion = tls.VersionName(state.Version)
+		info.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+		info.ALPN = state.NegotiatedProtocol
+		info.ServerName = state.ServerName
+		for _, cert := range state.PeerCertificates {
+			info.Certificates = append(info.Certificates, models.CertificateInfo{
+				Subject:   cert.Subject.String(),
+				Issuer:    cert.Issuer.String(),
+				DNSNames:  cert.DNSNames,
+				NotBefore: cert.NotBefore,
+				NotAfter:  cert.NotAfter,
+			})
+		}
+	}
+
+	return info
+}
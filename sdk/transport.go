@@ -0,0 +1,488 @@
+package slurpy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/intercept"
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// Transport is an http.RoundTripper that logs every request passing through it.
+// It can be installed on any *http.Client, including clients owned by
+// third-party SDKs, without changing their call sites.
+//
+// A Transport is safe for concurrent use, including while it is being
+// reconfigured. Each request reads a single snapshot of the settings when it
+// starts, so SetNamespace and SetEnabled only affect requests started after
+// they return, and an exchange in flight is never logged half under old and
+// half under new settings.
+type Transport struct {
+	base        http.RoundTripper
+	state       atomic.Pointer[transportState]
+	mu          sync.Mutex // Serializes writers of state
+	storeDir    string
+	maxReqBody  int64
+	maxRespBody int64
+	bodyRules   []BodyRule
+	redactor    *redactor
+	writer      *asyncWriter
+	mode        Mode
+	cassette    *cassette // Recordings to answer from in the replay modes
+	socket      string    // Where the CLI listens for requests paused at breakpoints
+
+	sessionsMu sync.Mutex
+	sessions   map[*wsSession]struct{} // Open WebSocket sessions, saved on Close
+}
+
+// NewTransport creates a logging Transport on top of base.
+// If base is nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, config Config) (*Transport, error) {
+	config, err := config.resolve()
+	if err != nil {
+		return nil, err
+	}
+	return newTransport(base, config)
+}
+
+// newTransport creates a Transport from an already resolved config
+func newTransport(base http.RoundTripper, config Config) (*Transport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	redactor, err := newRedactor(config.Redact)
+	if err != nil {
+		return nil, err
+	}
+	faults, err := compileFaults(config.Faults)
+	if err != nil {
+		return nil, err
+	}
+	network, err := copyNetwork(config.Network)
+	if err != nil {
+		return nil, err
+	}
+	breakpoints, err := compileBreakpoints(config.Breakpoints)
+	if err != nil {
+		return nil, err
+	}
+	// Without a home directory there is no CLI to pause for either, and
+	// breakpoints simply never fire
+	socket, _ := intercept.SocketPath(config.StoreDir)
+
+	var tape *cassette
+	switch config.Mode {
+	case "", ModeRecord, ModePassthrough:
+	case ModeReplay, ModeRecordMissing:
+		if tape, err = newCassette(config, redactor); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid mode %q: expected record, replay, record-missing or passthrough", config.Mode)
+	}
+
+	// Misses are only recorded by being logged, so record-missing logs even
+	// when Enabled is left false
+	enabled := config.Enabled || config.Mode == ModeRecordMissing
+
+	sink := config.Sink
+	if sink == nil && enabled {
+		if sink, err = NewFileSinkAt(config.StoreDir); err != nil {
+			return nil, err
+		}
+	}
+
+	t := &Transport{
+		base:        base,
+		storeDir:    config.StoreDir,
+		maxReqBody:  config.MaxRequestBodySize,
+		maxRespBody: config.MaxResponseBodySize,
+		bodyRules:   config.BodyRules,
+		redactor:    redactor,
+		writer:      newAsyncWriter(config.QueueSize, config.OverflowPolicy, config.ErrorHandler),
+		mode:        config.Mode,
+		cassette:    tape,
+		socket:      socket,
+	}
+	t.state.Store(&transportState{
+		namespace:   config.Namespace,
+		enabled:     enabled,
+		sink:        sink,
+		faults:      faults,
+		network:     network,
+		breakpoints: breakpoints,
+	})
+	return t, nil
+}
+
+// transportState is an immutable snapshot of the settings that can change
+// after a Transport is created. Updates swap in a new snapshot.
+type transportState struct {
+	namespace   string
+	enabled     bool
+	sink        Sink
+	faults      []FaultRule
+	network     *models.NetworkProfile
+	breakpoints []Breakpoint
+}
+
+// update applies fn to a copy of the current state and publishes the copy
+func (t *Transport) update(fn func(state *transportState) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	next := *t.state.Load()
+	if err := fn(&next); err != nil {
+		return err
+	}
+	t.state.Store(&next)
+	return nil
+}
+
+// Base returns the underlying RoundTripper
+func (t *Transport) Base() http.RoundTripper {
+	return t.base
+}
+
+// RoundTrip executes a single HTTP transaction and logs it.
+// The response body is not buffered: it is recorded as the caller reads it and
+// the exchange is saved once the body reaches EOF or is closed.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	state := t.state.Load()
+	opts := optionsFromContext(req.Context())
+	namespace := state.namespace
+	if opts.namespace != "" {
+		namespace = opts.namespace
+	}
+
+	// Breakpoints come first, so faults, replay and the log all see the
+	// request as edited in the CLI
+	pause := newPause(pickBreakpoint(state.breakpoints, req), req, t.socket, namespace)
+	req, err := pause.request(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fault := pickFault(state.faults, req)
+	base := shapeNetwork(state.network, t.base)
+	if t.mode == ModePassthrough {
+		return pause.response(fault.send(req, base))
+	}
+	if t.cassette != nil {
+		resp, missing, err := t.replay(req, fault, state.network)
+		if missing == nil {
+			return pause.response(resp, err)
+		}
+		req = missing
+	}
+
+	if !state.enabled || opts.skip {
+		return pause.response(fault.send(req, base))
+	}
+
+	ex := &exchange{
+		sink:     state.sink,
+		redactor: t.redactor,
+		writer:   t.writer,
+		cassette: t.cassette,
+		start:    time.Now(),
+		logged: &models.LoggedRequest{
+			ID:        generateID(),
+			Method:    req.Method,
+			URL:       req.URL.String(),
+			Headers:   models.HeadersFromHTTP(req.Header),
+			Namespace: namespace,
+			Direction: models.DirectionOutbound,
+			Tags:      opts.tags,
+			Fault:     fault.record(),
+			Network:   state.network,
+		},
+	}
+	ex.logged.Timestamp = ex.start
+
+	// http.Client sets Response on each redirect hop to the response that
+	// caused it, which still carries our body wrapper
+	if req.Response != nil {
+		if body, ok := req.Response.Body.(*captureBody); ok {
+			ex.logged.ParentID = body.exchange.logged.ID
+			ex.logged.RedirectIndex = body.exchange.logged.RedirectIndex + 1
+		}
+	}
+
+	// RoundTrippers must not modify the caller's request, so the timing trace
+	// and body capture are attached to a shallow copy
+	ex.trace = &exchangeTrace{start: ex.start}
+	outReq := req.WithContext(httptrace.WithClientTrace(req.Context(), ex.trace.clientTrace()))
+
+	// Record the request body as the base transport sends it
+	if req.Body != nil && req.Body != http.NoBody {
+		ex.reqBody = &captureBuffer{
+			limit: t.bodyLimit(req.Header.Get("Content-Type"), t.maxReqBody, opts),
+		}
+		outReq.Body = &teeBody{body: req.Body, buf: ex.reqBody}
+	}
+
+	// Execute the request
+	resp, err := pause.response(fault.send(outReq, base))
+	ex.logged.Breakpoint = pause.record()
+	if err != nil {
+		ex.logged.Error = err.Error()
+		ex.finish()
+		return nil, err
+	}
+
+	ex.logged.Response = &models.LoggedResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    models.HeadersFromHTTP(resp.Header),
+	}
+	// A synthetic response from a fault never used a connection
+	if !fault.answers() {
+		ex.logged.Response.Connection = ex.trace.connection(resp)
+	}
+
+	// Protocol upgrades hand back a read-write body. WebSocket connections
+	// are recorded frame by frame until closed; other protocols pass as is.
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		if conn, ok := resp.Body.(io.ReadWriteCloser); ok && isWebSocketUpgrade(resp.Header.Get("Upgrade")) {
+			resp.Body = &wsConn{conn: conn, session: t.openSession(ex, resp, opts)}
+			return resp, nil
+		}
+		ex.finish()
+		return resp, nil
+	}
+
+	// Redirects are always wrapped, even without a body, because the wrapper
+	// is how the next hop finds its parent.
+	redirect := resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != ""
+	if resp.Body == nil || (resp.Body == http.NoBody && !redirect) {
+		ex.finish()
+		return resp, nil
+	}
+
+	limit := t.bodyLimit(resp.Header.Get("Content-Type"), t.maxRespBody, opts)
+	resp.Body = &captureBody{
+		body:     resp.Body,
+		buf:      captureBuffer{limit: limit},
+		expected: resp.ContentLength,
+		exchange: ex,
+		stream:   newStreamRecorder(resp.Header, resp.ContentLength < 0, ex.start, limit),
+	}
+	return resp, nil
+}
+
+// openSession starts recording a WebSocket connection and tracks it until it
+// ends, so Close can save sessions that are still open
+func (t *Transport) openSession(ex *exchange, resp *http.Response, opts requestOptions) *wsSession {
+	// Show the session under the scheme the caller dialed
+	if rest, ok := strings.CutPrefix(ex.logged.URL, "http"); ok {
+		ex.logged.URL = "ws" + rest
+	}
+
+	extensions := resp.Header.Get("Sec-WebSocket-Extensions")
+	session := newWSSession(ex, t.bodyLimit("", t.maxReqBody, opts), t.bodyLimit("", t.maxRespBody, opts), extensions, func(s *wsSession) {
+		t.sessionsMu.Lock()
+		delete(t.sessions, s)
+		t.sessionsMu.Unlock()
+	})
+
+	t.sessionsMu.Lock()
+	if t.sessions == nil {
+		t.sessions = make(map[*wsSession]struct{})
+	}
+	t.sessions[session] = struct{}{}
+	t.sessionsMu.Unlock()
+	return session
+}
+
+// bodyLimit returns how many body bytes to store for one request
+func (t *Transport) bodyLimit(contentType string, max int64, opts requestOptions) int64 {
+	if opts.noBody {
+		return 0
+	}
+	return captureLimit(contentType, max, t.bodyRules)
+}
+
+// exchange tracks a single request/response cycle until it can be saved
+type exchange struct {
+	sink     Sink
+	redactor *redactor
+	writer   *asyncWriter
+	cassette *cassette // Set in ModeRecordMissing, which replays what it records
+	start    time.Time
+	logged   *models.LoggedRequest
+	reqBody  *captureBuffer
+	trace    *exchangeTrace // Client-side phases; nil for inbound exchanges
+}
+
+// finishResponse records the streamed response body and saves the exchange
+func (ex *exchange) finishResponse(buf *captureBuffer, partial bool, err error) {
+	resp := ex.logged.Response
+	resp.Size = buf.Size()
+	resp.Truncated = buf.Truncated()
+	resp.Partial = partial
+
+	// Compressed bodies are stored decoded so they are readable. The caller's
+	// bytes are never touched; only the captured copy is decoded.
+	body := buf.Bytes()
+	if encoding := resp.Headers.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		decoded, decodedSize, decodeErr := decodeContent(encoding, body, buf.limit)
+		if decodeErr == nil && len(body) > 0 {
+			resp.ContentEncoding = encoding
+			if decodedSize < 0 {
+				// Decoding stopped at the limit, so the full size is unknown
+				resp.Truncated = true
+			} else {
+				resp.DecodedSize = decodedSize
+				resp.CompressionRatio = float64(decodedSize) / float64(len(body))
+			}
+			body = decoded
+		}
+	}
+	resp.Body, resp.BodyEncoding = models.EncodeBody(body, resp.Headers.Get("Content-Type"))
+	if err != nil {
+		ex.logged.Error = err.Error()
+	}
+	ex.finish()
+}
+
+// finish records the request body and duration and saves the exchange
+func (ex *exchange) finish() {
+	end := time.Now()
+	ex.logged.Duration = end.Sub(ex.start)
+	if ex.trace != nil {
+		ex.logged.Timing = ex.trace.timing(end)
+	}
+	if ex.reqBody != nil {
+		ex.logged.Body, ex.logged.BodyEncoding = models.EncodeBody(ex.reqBody.Bytes(), ex.logged.Headers.Get("Content-Type"))
+		ex.logged.BodySize = ex.reqBody.Size()
+		ex.logged.Truncated = ex.reqBody.Truncated()
+	}
+
+	// Mask secrets before anything touches the disk
+	ex.redactor.apply(ex.logged)
+	if ex.cassette != nil {
+		ex.cassette.add(ex.logged)
+	}
+
+	// Saving happens in the background and never fails the original request
+	ex.writer.enqueue(writeEntry{req: ex.logged, sink: ex.sink})
+}
+
+// SetNamespace updates the namespace for future requests
+func (t *Transport) SetNamespace(namespace string) {
+	t.update(func(state *transportState) error {
+		state.namespace = namespace
+		return nil
+	})
+}
+
+// GetNamespace returns the current namespace
+func (t *Transport) GetNamespace() string {
+	return t.state.Load().namespace
+}
+
+// SetEnabled enables or disables request logging
+func (t *Transport) SetEnabled(enabled bool) error {
+	return t.update(func(state *transportState) error {
+		if enabled && state.sink == nil {
+			sink, err := NewFileSinkAt(t.storeDir)
+			if err != nil {
+				return err
+			}
+			state.sink = sink
+		}
+		state.enabled = enabled
+		return nil
+	})
+}
+
+// IsEnabled returns whether request logging is enabled
+func (t *Transport) IsEnabled() bool {
+	return t.state.Load().enabled
+}
+
+// SetNetwork switches the simulated network for requests started after it
+// returns. Pass nil to send at full speed.
+func (t *Transport) SetNetwork(profile *models.NetworkProfile) error {
+	network, err := copyNetwork(profile)
+	if err != nil {
+		return err
+	}
+	return t.update(func(state *transportState) error {
+		state.network = network
+		return nil
+	})
+}
+
+// SetBreakpoints replaces the breakpoints for requests started after it
+// returns. Pass nil to stop pausing requests.
+func (t *Transport) SetBreakpoints(breakpoints []Breakpoint) error {
+	compiled, err := compileBreakpoints(breakpoints)
+	if err != nil {
+		return err
+	}
+	return t.update(func(state *transportState) error {
+		state.breakpoints = compiled
+		return nil
+	})
+}
+
+// SetFaults replaces the fault rules for requests started after it returns.
+// Pass nil to stop injecting faults.
+func (t *Transport) SetFaults(rules []FaultRule) error {
+	faults, err := compileFaults(rules)
+	if err != nil {
+		return err
+	}
+	return t.update(func(state *transportState) error {
+		state.faults = faults
+		return nil
+	})
+}
+
+// Flush waits until every exchange finished so far has been written, or
+// until ctx is done
+func (t *Transport) Flush(ctx context.Context) error {
+	return t.writer.flush(ctx)
+}
+
+// Close writes any queued exchanges, stops the background writer and closes
+// the sink. Exchanges that finish after Close are dropped.
+func (t *Transport) Close() error {
+	// Save WebSocket sessions the caller has not closed yet
+	t.sessionsMu.Lock()
+	open := make([]*wsSession, 0, len(t.sessions))
+	for s := range t.sessions {
+		open = append(open, s)
+	}
+	t.sessionsMu.Unlock()
+	for _, s := range open {
+		s.end(nil, true)
+	}
+
+	if err := t.writer.close(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if sink := t.state.Load().sink; sink != nil {
+		return sink.Close()
+	}
+	return nil
+}
+
+// Dropped returns the number of exchanges discarded because the write queue
+// was full or the transport was closed
+func (t *Transport) Dropped() uint64 {
+	return t.writer.dropped.Load()
+}
@@ -0,0 +1,395 @@
+package slurpy
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// maxMessages is how many stream messages are stored per exchange. Later
+// messages are counted in MessagesDropped but not kept.
+const maxMessages = 1000
+
+// WebSocket opcodes from RFC 6455
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// wsOpcodeNames are the message types stored for each opcode
+var wsOpcodeNames = map[byte]string{
+	wsContinuation: "continuation",
+	wsText:         "text",
+	wsBinary:       "binary",
+	wsClose:        "close",
+	wsPing:         "ping",
+	wsPong:         "pong",
+}
+
+// isWebSocketUpgrade reports whether a 101 response switched to WebSocket
+func isWebSocketUpgrade(upgrade string) bool {
+	return strings.EqualFold(strings.TrimSpace(upgrade), "websocket")
+}
+
+// wsSession records the messages of an upgraded WebSocket connection until
+// it is closed, then saves the handshake and messages as a single exchange.
+// Fragmented messages are reassembled; control frames are recorded as they
+// arrive, even in the middle of a fragmented message.
+type wsSession struct {
+	exchange *exchange
+	mu       sync.Mutex
+	sent     *frameParser
+	received *frameParser
+	ended    bool // Frames after the session was saved are ignored
+	once     sync.Once
+	onEnd    func(*wsSession)
+}
+
+// newWSSession starts recording a session. Message payloads in each direction
+// are stored up to the matching body limit. extensions is the handshake's
+// Sec-WebSocket-Extensions response header.
+func newWSSession(ex *exchange, sentLimit, receivedLimit int64, extensions string, onEnd func(*wsSession)) *wsSession {
+	deflate := strings.Contains(extensions, "permessage-deflate")
+	s := &wsSession{exchange: ex, onEnd: onEnd}
+	sent := &wsAssembler{
+		direction:  models.MessageSent,
+		limit:      sentLimit,
+		deflate:    deflate,
+		noTakeover: strings.Contains(extensions, "client_no_context_takeover"),
+	}
+	received := &wsAssembler{
+		direction:  models.MessageReceived,
+		limit:      receivedLimit,
+		deflate:    deflate,
+		noTakeover: strings.Contains(extensions, "server_no_context_takeover"),
+	}
+	s.sent = &frameParser{limit: sentLimit, onFrame: func(f wsFrame) { s.frame(sent, f) }}
+	s.received = &frameParser{limit: receivedLimit, onFrame: func(f wsFrame) { s.frame(received, f) }}
+	return s
+}
+
+// frame handles one parsed frame, recording a message when one completes
+func (s *wsSession) frame(a *wsAssembler, f wsFrame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+	if msg, ok := a.add(f); ok {
+		s.record(msg)
+	}
+}
+
+// record stores a completed message
+func (s *wsSession) record(msg models.Message) {
+	lr := s.exchange.logged
+	if len(lr.Messages) >= maxMessages {
+		lr.MessagesDropped++
+		return
+	}
+	msg.Offset = time.Since(s.exchange.start)
+	lr.Messages = append(lr.Messages, msg)
+}
+
+// end saves the session once, whichever side closes it first
+func (s *wsSession) end(err error, stillOpen bool) {
+	s.once.Do(func() {
+		s.mu.Lock()
+		if err != nil && err != io.EOF {
+			s.exchange.logged.Error = err.Error()
+		}
+		s.exchange.logged.Response.Partial = stillOpen
+		s.ended = true
+		s.mu.Unlock()
+
+		if s.onEnd != nil {
+			s.onEnd(s)
+		}
+		s.exchange.finish()
+	})
+}
+
+// wsAssembler joins the frames of one direction into messages
+type wsAssembler struct {
+	direction  string
+	limit      int64
+	deflate    bool // permessage-deflate was negotiated
+	noTakeover bool // Each compressed message stands alone
+
+	opcode     byte
+	compressed bool
+	size       int64
+	payload    []byte
+
+	// window holds the last 32 KiB this direction decompressed, which the
+	// sender may refer back to unless it negotiated no context takeover.
+	// A message that could not be fully decompressed breaks the window.
+	window       []byte
+	windowBroken bool
+}
+
+// add takes the next frame and returns a message once one is complete
+func (a *wsAssembler) add(f wsFrame) (models.Message, bool) {
+	if f.opcode >= wsClose {
+		return a.message(f.opcode, false, f.size, f.payload), true
+	}
+
+	if f.opcode != wsContinuation {
+		a.opcode, a.compressed = f.opcode, f.rsv1 && a.deflate
+		a.size, a.payload = 0, nil
+	}
+	a.size += f.size
+	a.payload = append(a.payload, f.payload...)
+	if a.limit >= 0 && int64(len(a.payload)) > a.limit {
+		a.payload = a.payload[:a.limit]
+	}
+	if !f.fin {
+		return models.Message{}, false
+	}
+
+	msg := a.message(a.opcode, a.compressed, a.size, a.payload)
+	a.payload = nil
+	return msg, true
+}
+
+// message renders a complete message for storage
+func (a *wsAssembler) message(opcode byte, compressed bool, size int64, payload []byte) models.Message {
+	msg := models.Message{
+		Direction: a.direction,
+		Type:      wsOpcodeNames[opcode],
+		Size:      size,
+		Truncated: int64(len(payload)) < size,
+	}
+	if msg.Type == "" {
+		msg.Type = fmt.Sprintf("opcode %d", opcode)
+	}
+
+	switch {
+	case compressed:
+		if inflated, ok := a.inflate(payload, msg.Truncated); ok {
+			payload = inflated
+			msg.Size = int64(len(inflated))
+			if a.limit >= 0 && msg.Size > a.limit {
+				payload, msg.Truncated = payload[:a.limit], true
+			}
+		} else {
+			msg.Type += " (compressed)"
+		}
+	case opcode == wsClose && len(payload) >= 2:
+		code := binary.BigEndian.Uint16(payload)
+		payload = []byte(fmt.Sprintf("%d %s", code, payload[2:]))
+	}
+
+	contentType := "application/octet-stream"
+	if opcode == wsText || opcode >= wsClose {
+		contentType = "text/plain"
+	}
+	msg.Data, msg.Encoding = models.EncodeBody(payload, contentType)
+	return msg
+}
+
+// inflate decompresses a permessage-deflate message using the direction's
+// shared window
+func (a *wsAssembler) inflate(payload []byte, truncated bool) ([]byte, bool) {
+	if a.noTakeover {
+		a.window, a.windowBroken = nil, false
+	}
+	if truncated || a.windowBroken {
+		a.windowBroken = true
+		return nil, false
+	}
+
+	// The sender strips the tail of the final empty block, per RFC 7692
+	src := io.MultiReader(bytes.NewReader(payload), bytes.NewReader([]byte{0x00, 0x00, 0xff, 0xff}))
+	r := flate.NewReaderDict(src, a.window)
+	defer r.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		a.windowBroken = true
+		return nil, false
+	}
+
+	a.window = append(a.window, out...)
+	if len(a.window) > 32<<10 {
+		a.window = append([]byte(nil), a.window[len(a.window)-32<<10:]...)
+	}
+	return out, true
+}
+
+// wsConn wraps the read-write body of a 101 response so the caller uses the
+// connection normally while the frames in both directions are recorded
+type wsConn struct {
+	conn    io.ReadWriteCloser
+	session *wsSession
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	n, err := c.conn.Read(p)
+	if n > 0 {
+		c.session.received.write(p[:n])
+	}
+	if err != nil {
+		c.session.end(err, false)
+	}
+	return n, err
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	n, err := c.conn.Write(p)
+	if n > 0 {
+		c.session.sent.write(p[:n])
+	}
+	return n, err
+}
+
+func (c *wsConn) Close() error {
+	err := c.conn.Close()
+	c.session.end(nil, false)
+	return err
+}
+
+// wsFrame is a parsed frame. payload holds at most the parser's limit of
+// unmasked bytes; size is the full payload length.
+type wsFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  byte
+	size    int64
+	payload []byte
+}
+
+// frameParser splits one direction of a WebSocket byte stream into frames.
+// Bytes arrive in arbitrary chunks, so the header and payload of a frame are
+// assembled across calls to write. Payload bytes beyond the limit are counted
+// but not kept.
+type frameParser struct {
+	limit   int64
+	onFrame func(wsFrame)
+
+	header    []byte
+	frame     *wsFrame
+	remaining int64
+	masked    bool
+	maskKey   [4]byte
+	pos       int64 // Payload bytes seen so far, for unmasking
+}
+
+// write feeds stream bytes to the parser
+func (p *frameParser) write(b []byte) {
+	for len(b) > 0 {
+		if p.frame == nil {
+			p.header = append(p.header, b[0])
+			b = b[1:]
+			if p.headerComplete() {
+				p.startFrame()
+			}
+			continue
+		}
+
+		take := int64(len(b))
+		if take > p.remaining {
+			take = p.remaining
+		}
+		p.keep(b[:take])
+		b = b[take:]
+		p.remaining -= take
+		if p.remaining == 0 {
+			p.endFrame()
+		}
+	}
+}
+
+// headerComplete reports whether enough bytes have arrived for the header,
+// whose length depends on the payload length encoding and the mask bit
+func (p *frameParser) headerComplete() bool {
+	h := p.header
+	if len(h) < 2 {
+		return false
+	}
+	need := 2
+	switch h[1] & 0x7f {
+	case 126:
+		need += 2
+	case 127:
+		need += 8
+	}
+	if h[1]&0x80 != 0 {
+		need += 4
+	}
+	return len(h) >= need
+}
+
+// startFrame decodes the completed header
+func (p *frameParser) startFrame() {
+	h := p.header
+	f := &wsFrame{
+		fin:    h[0]&0x80 != 0,
+		rsv1:   h[0]&0x40 != 0,
+		opcode: h[0] & 0x0f,
+	}
+
+	rest := h[2:]
+	switch length := h[1] & 0x7f; length {
+	case 126:
+		f.size = int64(binary.BigEndian.Uint16(rest))
+		rest = rest[2:]
+	case 127:
+		f.size = int64(binary.BigEndian.Uint64(rest) &^ (1 << 63))
+		rest = rest[8:]
+	default:
+		f.size = int64(length)
+	}
+
+	p.masked = h[1]&0x80 != 0
+	if p.masked {
+		copy(p.maskKey[:], rest)
+	}
+
+	p.frame, p.remaining, p.pos = f, f.size, 0
+	p.header = p.header[:0]
+	if p.remaining == 0 {
+		p.endFrame()
+	}
+}
+
+// keep stores the unmasked part of chunk that fits in the limit
+func (p *frameParser) keep(chunk []byte) {
+	limit := p.limit
+	// Control frames are at most 125 bytes and always worth keeping
+	if p.frame.opcode >= wsClose || limit < 0 {
+		limit = p.frame.size
+	}
+
+	room := limit - int64(len(p.frame.payload))
+	if room > int64(len(chunk)) {
+		room = int64(len(chunk))
+	}
+	for i := int64(0); i < room; i++ {
+		c := chunk[i]
+		if p.masked {
+			c ^= p.maskKey[(p.pos+i)%4]
+		}
+		p.frame.payload = append(p.frame.payload, c)
+	}
+	p.pos += int64(len(chunk))
+}
+
+// endFrame hands the finished frame to the session
+func (p *frameParser) endFrame() {
+	f := *p.frame
+	p.frame = nil
+	p.onFrame(f)
+}
@@ -0,0 +1,205 @@
+package slurpy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bobby/slurpy/pkg/models"
+)
+
+// DefaultQueueSize is the number of exchanges buffered for the background
+// writer when Config leaves QueueSize unset
+const DefaultQueueSize = 1024
+
+// ErrClosed is reported for exchanges that finish after the client was closed
+var ErrClosed = errors.New("client is closed")
+
+// OverflowPolicy decides what happens to a new exchange when the background
+// writer's queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowDropOldest discards the oldest queued exchange to make room
+	OverflowDropOldest OverflowPolicy = iota
+	// OverflowDropNew discards the exchange that did not fit
+	OverflowDropNew
+	// OverflowBlock makes the caller wait until there is room
+	OverflowBlock
+)
+
+// defaultErrorHandler reports logging failures on stderr
+func defaultErrorHandler(err error) {
+	log.Printf("slurpy: %v", err)
+}
+
+// writeEntry is a finished exchange waiting to be persisted
+type writeEntry struct {
+	req  *models.LoggedRequest
+	sink Sink
+}
+
+// asyncWriter persists finished exchanges on a background goroutine so disk
+// latency never lands on the caller's request
+type asyncWriter struct {
+	queue   chan writeEntry
+	policy  OverflowPolicy
+	onError func(error)
+	dropped atomic.Uint64
+
+	start sync.Once
+	done  chan struct{}
+
+	mu     sync.RWMutex // Held for reading while enqueueing, for writing on close
+	closed bool
+
+	progressMu sync.Mutex
+	queued     uint64        // Entries placed on the queue so far
+	written    uint64        // Entries taken off the queue, written or dropped
+	progress   chan struct{} // Closed when written next grows; nil until flush waits
+}
+
+// newAsyncWriter creates a writer with the given queue size and policy
+func newAsyncWriter(size int, policy OverflowPolicy, onError func(error)) *asyncWriter {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	if onError == nil {
+		onError = defaultErrorHandler
+	}
+
+	return &asyncWriter{
+		queue:   make(chan writeEntry, size),
+		policy:  policy,
+		onError: onError,
+		done:    make(chan struct{}),
+	}
+}
+
+// enqueue hands an exchange to the background goroutine, applying the
+// overflow policy when the queue is full
+func (w *asyncWriter) enqueue(entry writeEntry) {
+	w.start.Do(func() {
+		go w.run()
+	})
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		w.dropped.Add(1)
+		w.onError(fmt.Errorf("dropped request %s: %w", entry.req.ID, ErrClosed))
+		return
+	}
+
+	for {
+		select {
+		case w.queue <- entry:
+			w.addQueued()
+			return
+		default:
+		}
+
+		switch w.policy {
+		case OverflowBlock:
+			w.queue <- entry
+			w.addQueued()
+			return
+		case OverflowDropNew:
+			w.dropped.Add(1)
+			return
+		default:
+			select {
+			case <-w.queue:
+				w.dropped.Add(1)
+				w.addWritten()
+			default:
+			}
+		}
+	}
+}
+
+// run writes queued exchanges until the queue is closed
+func (w *asyncWriter) run() {
+	defer close(w.done)
+
+	for entry := range w.queue {
+		if entry.sink == nil {
+			w.onError(fmt.Errorf("failed to save request log %s: no sink configured", entry.req.ID))
+		} else if err := entry.sink.Write(context.Background(), entry.req); err != nil {
+			w.onError(fmt.Errorf("failed to save request log %s: %w", entry.req.ID, err))
+		}
+		w.addWritten()
+	}
+}
+
+// addQueued counts an entry placed on the queue
+func (w *asyncWriter) addQueued() {
+	w.progressMu.Lock()
+	defer w.progressMu.Unlock()
+
+	w.queued++
+}
+
+// addWritten counts an entry taken off the queue and wakes waiting flushes
+func (w *asyncWriter) addWritten() {
+	w.progressMu.Lock()
+	defer w.progressMu.Unlock()
+
+	w.written++
+	if w.progress != nil {
+		close(w.progress)
+		w.progress = nil
+	}
+}
+
+// flush waits until every exchange queued so far has been written. Entries
+// leave the queue in order, so that is once as many have been taken off as
+// had been placed on it when flush was called; later traffic does not delay
+// it.
+func (w *asyncWriter) flush(ctx context.Context) error {
+	w.progressMu.Lock()
+	target := w.queued
+	w.progressMu.Unlock()
+
+	for {
+		w.progressMu.Lock()
+		if w.written >= target {
+			w.progressMu.Unlock()
+			return nil
+		}
+		if w.progress == nil {
+			w.progress = make(chan struct{})
+		}
+		progress := w.progress
+		w.progressMu.Unlock()
+
+		select {
+		case <-progress:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// close stops accepting exchanges and waits for the queue to drain
+func (w *asyncWriter) close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	// Make sure the goroutine exists to drain the queue and close done
+	w.start.Do(func() {
+		go w.run()
+	})
+	<-w.done
+	return nil
+}